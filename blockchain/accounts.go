@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// BondingCurveFor reads and decodes the on-chain bonding curve account
+func (b *BlockchainClient) BondingCurveFor(bondingCurveAddress string) (*curve.BondingCurve, error) {
+	bondingCurvePubKey, err := solana.PublicKeyFromBase58(bondingCurveAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bonding curve address: %v", err)
+	}
+
+	data, err := b.accountsData(bondingCurvePubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return curve.DecodeBondingCurve(data[0])
+}
+
+// Global reads and decodes the pump.fun global account
+func (b *BlockchainClient) Global() (*curve.Global, error) {
+	data, err := b.accountsData(PUMP_GLOBAL)
+	if err != nil {
+		return nil, err
+	}
+
+	return curve.DecodeGlobal(data[0])
+}
+
+// curveFor fetches the bonding curve and global accounts in one round trip and returns a quoter
+// priced at the live protocol fee
+func (b *BlockchainClient) curveFor(bondingCurvePubKey solana.PublicKey) (*curve.BondingCurve, *curve.Curve, error) {
+	data, err := b.accountsData(bondingCurvePubKey, PUMP_GLOBAL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bondingCurve, err := curve.DecodeBondingCurve(data[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode bonding curve: %w", err)
+	}
+
+	global, err := curve.DecodeGlobal(data[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode global account: %w", err)
+	}
+
+	if bondingCurve.Complete {
+		return nil, nil, fmt.Errorf("bonding curve %s: %w", bondingCurvePubKey, ErrCurveComplete)
+	}
+
+	return bondingCurve, bondingCurve.Curve(global.FeeBasisPoints), nil
+}
+
+func (b *BlockchainClient) accountsData(accounts ...solana.PublicKey) ([][]byte, error) {
+	result, err := b.client.GetMultipleAccountsWithOpts(
+		context.Background(),
+		accounts,
+		&rpc.GetMultipleAccountsOpts{
+			Encoding:   solana.EncodingBase64,
+			Commitment: rpc.CommitmentProcessed,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	data := make([][]byte, len(accounts))
+	for i, account := range result.Value {
+		if account == nil || account.Data == nil {
+			return nil, fmt.Errorf("account %s not found", accounts[i])
+		}
+		data[i] = account.Data.GetBinary()
+	}
+
+	return data, nil
+}
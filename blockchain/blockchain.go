@@ -2,23 +2,29 @@ package blockchain
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"time"
 
 	"github.com/ethanhosier/pumpfun-trade-bot/coinInfo"
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
 	"github.com/ethanhosier/pumpfun-trade-bot/utils"
 	"github.com/gagliardetto/solana-go"
-	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
 	"github.com/gagliardetto/solana-go/programs/system"
 	"github.com/gagliardetto/solana-go/rpc"
-	"github.com/gorilla/websocket"
 )
 
 const (
 	lamportsPerSol   = 1_000_000_000
 	computeUnitLimit = 68000 // maybe make this smaller?
-	priorityFee      = 100
+
+	minPriorityFee          = 100     // micro-lamports per compute unit
+	maxPriorityFee          = 200_000 // micro-lamports per compute unit
+	feeEscalationMultiplier = 2
+	buyFeePercentile        = 75
+	sellFeePercentile       = 90
 
 	wsEndpoint   = "wss://mainnet.helius-rpc.com/?api-key=" // REMEMBER TO ADD THE %s BACK
 	restEndpoint = "https://mainnet.helius-rpc.com/?api-key="
@@ -45,15 +51,81 @@ type BlockchainClient struct {
 	apiKey         string
 	client         *rpc.Client
 	coinInfoClient *coinInfo.CoinInfoClient
+
+	wsUrl               string
+	subscriptionOptions subscriptionOptions
+
+	confirmationCommitment rpc.CommitmentType
+	confirmationOptions    confirmationOptions
+
+	feeEstimator      *FeeEstimator
+	buyFeePercentile  float64
+	sellFeePercentile float64
+
+	blockhashCache *BlockhashCache
+
+	txSender       TxSender
+	txSenderPinned bool        // set through SetTxSender, so new endpoints leave it in place
+	endpoints      []*endpoint // read endpoints first, then send-only ones
+
+	lookupTables map[solana.PublicKey]solana.PublicKeySlice // nil builds legacy transactions
 }
 
 func NewBlockchainClient(apiKey string, coinInfoClient *coinInfo.CoinInfoClient) *BlockchainClient {
-	return &BlockchainClient{apiKey, rpc.New(fmt.Sprintf("%s%s", restEndpoint, apiKey)), coinInfoClient}
+	b := &BlockchainClient{
+		apiKey:              apiKey,
+		coinInfoClient:      coinInfoClient,
+		wsUrl:               fmt.Sprintf("%s%s", wsEndpoint, apiKey),
+		subscriptionOptions: defaultSubscriptionOptions(),
+
+		confirmationCommitment: rpc.CommitmentConfirmed,
+		confirmationOptions:    defaultConfirmationOptions(),
+
+		feeEstimator:      NewFeeEstimator(nil, minPriorityFee, maxPriorityFee, feeEscalationMultiplier),
+		buyFeePercentile:  buyFeePercentile,
+		sellFeePercentile: sellFeePercentile,
+
+		blockhashCache: NewBlockhashCache(nil, rpc.CommitmentConfirmed, blockhashRefreshInterval),
+	}
+
+	// just the primary Helius endpoint until more are configured
+	b.SetEndpoints(nil, nil)
+
+	return b
+}
+
+// SetPriorityFeePercentiles sets which percentile of recent prioritization fees buys and sells pay
+func (b *BlockchainClient) SetPriorityFeePercentiles(buyPercentile float64, sellPercentile float64) {
+	b.buyFeePercentile = buyPercentile
+	b.sellFeePercentile = sellPercentile
+}
+
+// SetTxSender swaps how buys and sells are submitted, e.g. to a BundleSender for contested launches.
+// It stays in place whether it is set before or after SetEndpoints.
+func (b *BlockchainClient) SetTxSender(sender TxSender) {
+	b.txSender = sender
+	b.txSenderPinned = true
+}
+
+// StartBlockhashRefresher keeps a recent blockhash cached in the background until done is closed,
+// taking the blockhash fetch off the buy and sell path
+func (b *BlockchainClient) StartBlockhashRefresher(done <-chan interface{}) {
+	b.blockhashCache.Start(done)
+}
+
+// SetBlockhashCommitment sets the commitment blockhashes are fetched at. Call before starting the refresher.
+func (b *BlockchainClient) SetBlockhashCommitment(commitment rpc.CommitmentType) {
+	b.blockhashCache.commitment = commitment
 }
 
-func (b *BlockchainClient) GetTransactionDataWithRetries(signature string, maxRetries int) (*Transaction, error) {
+// SetConfirmationCommitment sets the commitment buys and sells wait for before returning
+func (b *BlockchainClient) SetConfirmationCommitment(commitment rpc.CommitmentType) {
+	b.confirmationCommitment = commitment
+}
+
+func (b *BlockchainClient) GetTransactionDataWithRetries(signature string, maxRetries int) (*ParsedTransaction, error) {
 	for i := 0; i < maxRetries; i++ {
-		tx, err := b.getTransactionData(signature)
+		tx, err := b.GetTransaction(signature)
 		if err == nil {
 			return tx, nil
 		}
@@ -63,37 +135,38 @@ func (b *BlockchainClient) GetTransactionDataWithRetries(signature string, maxRe
 	return nil, fmt.Errorf("failed to get transaction data after %d retries", maxRetries)
 }
 
+// SubscribeToWalletsTransactionSignatures streams the signatures of every transaction mentioning the wallets.
+// Dropped connections are redialled and resubscribed, and any signatures missed in the meantime are
+// backfilled; errCh only receives an error once reconnecting has been given up on.
 func (b *BlockchainClient) SubscribeToWalletsTransactionSignatures(walletAddresses []string, done <-chan interface{}) (<-chan WalletTransactionSignature, <-chan error, error) {
-	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("%s%s", wsEndpoint, b.apiKey), nil)
+	conn, subscriptionToWallet, err := b.connectAndSubscribe(walletAddresses)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to connect to WebSocket: %v", err)
-	}
-
-	subscriptionToWallet := make(map[int]string)
-
-	for _, wallet := range walletAddresses {
-		subscriptionID, err := b.subscribeToWalletTransactions(conn, wallet)
-		if err != nil {
-			return nil, nil, err
-		}
-		subscriptionToWallet[subscriptionID] = wallet
+		return nil, nil, err
 	}
 
 	walletTransactionSignaturesCh := make(chan WalletTransactionSignature, channelBufferSize)
-	errCh := make(chan error)
+	errCh := make(chan error, 1)
 
-	go b.transactionSignaturesLoop(conn, done, walletTransactionSignaturesCh, errCh, subscriptionToWallet)
+	go b.transactionSignaturesLoop(conn, walletAddresses, subscriptionToWallet, done, walletTransactionSignaturesCh, errCh)
 
 	return walletTransactionSignaturesCh, errCh, nil
 }
 
-func (b *BlockchainClient) SendSolanaToWallet(amountInSol float64, senderPrivateKey string, receiverPublicKey string) (string, error) {
-	// Decode private key from base58
-	privateKey, err := solana.PrivateKeyFromBase58(senderPrivateKey)
+// SubscribeToTokenLaunches streams every new token created through the pump program
+func (b *BlockchainClient) SubscribeToTokenLaunches(done <-chan interface{}) (<-chan TokenLaunch, <-chan error, error) {
+	programTransactionSignaturesCh, errCh, err := b.SubscribeToWalletsTransactionSignatures([]string{PUMP_PROGRAM.String()}, done)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode private key: %v", err)
+		return nil, nil, fmt.Errorf("failed to subscribe to pump program: %w", err)
 	}
 
+	tokenLaunchesCh := make(chan TokenLaunch, channelBufferSize)
+
+	go tokenLaunchesLoop(programTransactionSignaturesCh, done, tokenLaunchesCh)
+
+	return tokenLaunchesCh, errCh, nil
+}
+
+func (b *BlockchainClient) SendSolanaToWallet(amountInSol float64, sender Signer, receiverPublicKey string) (string, error) {
 	// Parse receiver's public key
 	receiver, err := solana.PublicKeyFromBase58(receiverPublicKey)
 	if err != nil {
@@ -103,34 +176,28 @@ func (b *BlockchainClient) SendSolanaToWallet(amountInSol float64, senderPrivate
 	// Create transfer instruction
 	transferIx := system.NewTransferInstruction(
 		uint64(amountInSol*lamportsPerSol),
-		privateKey.PublicKey(),
+		sender.PublicKey(),
 		receiver,
 	).Build()
 
-	// Get latest blockhash (replacing GetRecentBlockhash)
-	recent, err := b.client.GetLatestBlockhash(context.Background(), rpc.CommitmentFinalized)
+	// Get latest blockhash
+	recent, err := b.blockhashCache.Latest()
 	if err != nil {
 		return "", fmt.Errorf("failed to get latest blockhash: %v", err)
 	}
 
-	// Build transaction (updated to use new blockhash response)
+	// Build transaction
 	tx, err := solana.NewTransaction(
 		[]solana.Instruction{transferIx},
-		recent.Value.Blockhash,
-		solana.TransactionPayer(privateKey.PublicKey()),
+		recent.Blockhash,
+		solana.TransactionPayer(sender.PublicKey()),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create transaction: %v", err)
 	}
 
 	// Sign transaction
-	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
-		if privateKey.PublicKey().Equals(key) {
-			return &privateKey
-		}
-		return nil
-	})
-	if err != nil {
+	if err := signTransaction(tx, sender); err != nil {
 		return "", fmt.Errorf("failed to sign transaction: %v", err)
 	}
 
@@ -148,26 +215,25 @@ func (b *BlockchainClient) BuyTokenWithSol(
 	bondingCurveAddress string,
 	associatedBondingCurveAddress string,
 	solAmount float64,
-	slippage float64,
-	privateKey string,
+	slippageBps uint64,
+	signer Signer,
+	opts *TradeOptions,
 ) (*BuyTokenResult, error) {
 
-	blockhashTask := utils.DoAsync(func() (*rpc.GetLatestBlockhashResult, error) {
-		return b.client.GetLatestBlockhash(context.Background(), rpc.CommitmentFinalized)
-	})
+	blockhashTask := utils.DoAsync(b.blockhashCache.Latest)
 
 	// Convert unique data to required formats
 	mintPubKey, bondingCurvePubKey, associatedBondingCurvePubKey, err := pubKeysFrom(tokenMint, bondingCurveAddress, associatedBondingCurveAddress)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse pub keys: %w", err)
 	}
-	signer, err := solana.PrivateKeyFromBase58(privateKey)
-	if err != nil {
-		return nil, fmt.Errorf("invalid private key: %w", err)
-	}
 	payerPubKey := signer.PublicKey()
 
-	ata, ataCreateInstruction, err := b.getOrCreateTokenAccountInstruction(mintPubKey, signer)
+	priorityFeeTask := utils.DoAsync(func() (uint64, error) {
+		return b.feeEstimator.EstimateForAttempt(b.buyFeePercentile, 0, PUMP_PROGRAM, bondingCurvePubKey), nil
+	})
+
+	ata, ataCreateInstruction, err := b.getOrCreateTokenAccountInstruction(mintPubKey, payerPubKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get or create associated token account: %w", err)
 	}
@@ -177,22 +243,21 @@ func (b *BlockchainClient) BuyTokenWithSol(
 		return nil, fmt.Errorf("invalid associated token account address: %w", err)
 	}
 
-	price, err := b.coinInfoClient.PriceInSolFromBondingCurveAddress(bondingCurveAddress)
+	_, bondingCurve, err := b.curveFor(bondingCurvePubKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get price in SOL from bonding curve address: %w", err)
+		return nil, fmt.Errorf("failed to get curve from bonding curve address: %w", err)
 	}
 
-	// Replace the calculation with function call
-	amountInLamports, maxAmountLamports, tokenAmount, err := buyTokenAmountsFrom(solAmount, price, slippage)
+	quote, err := bondingCurve.BuyExactSolIn(uint64(solAmount * lamportsPerSol))
 	if err != nil {
-		return nil, fmt.Errorf("failed to calculate token amounts: %w", err)
+		return nil, fmt.Errorf("failed to quote buy: %w", err)
 	}
+	maxSolCost := curve.MaxSolCost(quote.SolAmount, slippageBps)
 
-	buyInstruction := solana.NewInstruction(
-		PUMP_PROGRAM, // PUMP_PROGRAM
-		buyAccountsFrom(mintPubKey, bondingCurvePubKey, associatedBondingCurvePubKey, ataPubKey, payerPubKey), // AccountMetaSlice
-		buyDataFrom(amountInLamports, maxAmountLamports),                                                      // Instruction data
-	)
+	buyInstruction, err := buyInstructionFrom(mintPubKey, bondingCurvePubKey, associatedBondingCurvePubKey, ataPubKey, payerPubKey, quote.TokenAmount, maxSolCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build buy instruction: %w", err)
+	}
 
 	// Send the transaction
 	blockhash, err := utils.GetAsync(blockhashTask)
@@ -200,93 +265,79 @@ func (b *BlockchainClient) BuyTokenWithSol(
 		return nil, fmt.Errorf("failed to fetch recent blockhash: %w", err)
 	}
 
-	tx, err := solana.NewTransaction(
-		buyInstructionsFrom(computeUnitLimit, ataCreateInstruction, buyInstruction),
-		blockhash.Value.Blockhash,
-		solana.TransactionPayer(payerPubKey),
-	)
+	priorityFee, err := utils.GetAsync(priorityFeeTask)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create buy transaction: %w", err)
+		return nil, fmt.Errorf("failed to estimate priority fee: %w", err)
 	}
 
-	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
-		if signer.PublicKey().Equals(key) {
-			return &signer
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to sign transaction: %w", err)
-	}
-
-	txID, err := b.client.SendTransaction(context.Background(), tx)
+	sent, err := b.signAndSend(buyInstructionsFrom(ataCreateInstruction, buyInstruction), priorityFee, blockhash, signer, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send buy transaction: %w", err)
 	}
 
-	// Poll for transaction confirmation
-	err = b.waitForConfirmation(txID)
+	confirmation, err := b.ConfirmTransaction(sent.signature, sent.lastValidBlockHeight, b.confirmationCommitment)
 	if err != nil {
 		return nil, fmt.Errorf("transaction confirmation failed: %w", err)
 	}
 
-	fmt.Printf("Transaction successful. TXID: %s\n", txID)
-	return &BuyTokenResult{TxID: txID.String(), AmountInLampts: amountInLamports, MaxAmountLampts: maxAmountLamports, AssociatedTokenAccountAddress: ata, TokenAmount: tokenAmount}, nil
-}
-
-func (b *BlockchainClient) waitForConfirmation(sig solana.Signature) error {
-	for i := 0; i < 50; i++ { // Try for about 25 seconds
-		confirmation, err := b.client.GetSignatureStatuses(
-			context.Background(),
-			true,
-			sig,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to get transaction status: %w", err)
-		}
+	fmt.Printf("Transaction successful. TXID: %s\n", sent.signature)
+	result := &BuyTokenResult{TxID: sent.signature.String(), AmountInLampts: quote.SolAmount, MaxAmountLampts: maxSolCost, AssociatedTokenAccountAddress: ata, TokenAmount: uiTokenAmount(quote.TokenAmount), Quote: quote, Confirmation: confirmation, ComputeUnitsConsumed: sent.computeUnitsConsumed, SentAt: sent.sentAt}
 
-		if confirmation.Value[0] != nil {
-			if confirmation.Value[0].Err != nil {
-				return fmt.Errorf("transaction failed: %v", confirmation.Value[0].Err)
-			}
-			if confirmation.Value[0].Confirmations != nil && *confirmation.Value[0].Confirmations > 0 {
-				return nil
-			}
-		}
-		time.Sleep(500 * time.Millisecond)
+	// the buy landed either way, so a failed read back only leaves the quoted figures
+	if result.Fill, err = b.fillFor(sent.signature, payerPubKey, mintPubKey, bondingCurvePubKey, quote, true); err != nil {
+		log.Printf("Failed to reconcile buy fill for %s: %v", sent.signature, err)
+	} else {
+		result.TokenAmount = result.Fill.UiTokenAmount()
 	}
-	return fmt.Errorf("transaction confirmation timed out")
+
+	return result, nil
 }
 
+// SellToken sells the whole token account balance
 func (b *BlockchainClient) SellToken(
 	tokenMint string,
 	bondingCurveAddress string,
 	associatedBondingCurveAddress string,
 	associatedTokenAccountAddress string,
-	slippage float64,
-	privateKey string,
+	slippageBps uint64,
+	signer Signer,
+	opts *TradeOptions,
 ) (string, error) {
-	// Get latest blockhash asynchronously
-	blockhashTask := utils.DoAsync(func() (*rpc.GetLatestBlockhashResult, error) {
-		return b.client.GetLatestBlockhash(context.Background(), rpc.CommitmentFinalized)
-	})
-
-	// Convert addresses to public keys
-	mintPubKey, bondingCurvePubKey, associatedBondingCurvePubKey, err := pubKeysFrom(tokenMint, bondingCurveAddress, associatedBondingCurveAddress)
+	result, err := b.SellTokenAmount(tokenMint, bondingCurveAddress, associatedBondingCurveAddress, associatedTokenAccountAddress, SellAll(), slippageBps, signer, opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse pub keys: %w", err)
+		return "", err
+	}
+	return result.TxID, nil
+}
+
+// SellTokenAmount sells part or all of the token account balance, quoted from the live curve
+func (b *BlockchainClient) SellTokenAmount(
+	tokenMint string,
+	bondingCurveAddress string,
+	associatedBondingCurveAddress string,
+	associatedTokenAccountAddress string,
+	sellAmount SellAmount,
+	slippageBps uint64,
+	signer Signer,
+	opts *TradeOptions,
+) (*SellTokenResult, error) {
+	if opts == nil {
+		opts = &TradeOptions{}
 	}
 
-	// Parse private key
-	signer, err := solana.PrivateKeyFromBase58(privateKey)
+	// Get latest blockhash asynchronously, usually straight from the cache
+	blockhashTask := utils.DoAsync(b.blockhashCache.Latest)
+
+	// Convert addresses to public keys
+	mintPubKey, bondingCurvePubKey, associatedBondingCurvePubKey, err := pubKeysFrom(tokenMint, bondingCurveAddress, associatedBondingCurveAddress)
 	if err != nil {
-		return "", fmt.Errorf("invalid private key: %w", err)
+		return nil, fmt.Errorf("failed to parse pub keys: %w", err)
 	}
 
 	// Get ATA public key
 	ataPubKey, err := solana.PublicKeyFromBase58(associatedTokenAccountAddress)
 	if err != nil {
-		return "", fmt.Errorf("invalid associated token account address: %w", err)
+		return nil, fmt.Errorf("invalid associated token account address: %w", err)
 	}
 
 	// Get token balance
@@ -296,75 +347,74 @@ func (b *BlockchainClient) SellToken(
 		rpc.CommitmentFinalized,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to get token balance: %w", err)
+		return nil, fmt.Errorf("failed to get token balance: %w", err)
 	}
 
-	if tokenBalance.Value.Amount == "0" {
-		return "", fmt.Errorf("no tokens to sell")
+	balance, err := strconv.ParseUint(tokenBalance.Value.Amount, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token amount: %w", err)
 	}
 
-	// Get price from bonding curve
-	price, err := b.coinInfoClient.PriceInSolFromBondingCurveAddress(bondingCurveAddress)
+	// Get curve state from bonding curve
+	_, bondingCurve, err := b.curveFor(bondingCurvePubKey)
+	if errors.Is(err, ErrCurveComplete) {
+		// the coin graduated, so the curve no longer trades and what we hold sells on its pool
+		pool, err := b.RaydiumPoolFor(tokenMint)
+		if err != nil {
+			return nil, fmt.Errorf("bonding curve complete: %w", err)
+		}
+		return b.SellTokenOnPool(tokenMint, pool.String(), associatedTokenAccountAddress, sellAmount, slippageBps, signer, opts)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to get price from bonding curve: %w", err)
+		return nil, fmt.Errorf("failed to get curve from bonding curve: %w", err)
 	}
 
-	// Calculate minimum SOL output with slippage
-	amount, err := strconv.ParseUint(tokenBalance.Value.Amount, 10, 64)
+	// Calculate amount to sell and minimum SOL output with slippage
+	quote, err := sellAmount.quote(balance, bondingCurve)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse token amount: %w", err)
+		return nil, err
 	}
-
-	minSolOutput := uint64(float64(amount) * price * (1 - slippage))
+	minSolOutput := curve.MinSolOutput(quote.SolAmount, slippageBps)
 
 	// Create sell instruction
-	sellInstruction := solana.NewInstruction(
-		PUMP_PROGRAM,
-		sellAccountsFrom(mintPubKey, bondingCurvePubKey, associatedBondingCurvePubKey, ataPubKey, signer.PublicKey()),
-		sellDataFrom(amount, minSolOutput),
-	)
+	sellInstruction, err := sellInstructionFrom(mintPubKey, bondingCurvePubKey, associatedBondingCurvePubKey, ataPubKey, signer.PublicKey(), quote.TokenAmount, minSolOutput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sell instruction: %w", err)
+	}
+
+	instructions := []solana.Instruction{sellInstruction}
+	closeAccount := opts.CloseAccount && quote.TokenAmount == balance
+	if closeAccount {
+		instructions = append(instructions, closeAccountInstructionFrom(ataPubKey, signer.PublicKey()))
+	}
+
+	priorityFee := b.feeEstimator.EstimateForAttempt(b.sellFeePercentile, opts.Attempt, PUMP_PROGRAM, bondingCurvePubKey)
 
 	// Get blockhash result
 	blockhash, err := utils.GetAsync(blockhashTask)
 	if err != nil {
-		return "", fmt.Errorf("failed to get recent blockhash: %w", err)
+		return nil, fmt.Errorf("failed to get recent blockhash: %w", err)
 	}
 
-	// Create and sign transaction
-	tx, err := solana.NewTransaction(
-		[]solana.Instruction{
-			computebudget.NewSetComputeUnitLimitInstruction(computeUnitLimit).Build(),
-			computebudget.NewSetComputeUnitPriceInstruction(priorityFee).Build(),
-			sellInstruction,
-		},
-		blockhash.Value.Blockhash,
-		solana.TransactionPayer(signer.PublicKey()),
-	)
+	// Simulate, sign and send transaction
+	sent, err := b.signAndSend(instructions, priorityFee, blockhash, signer, opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to create sell transaction: %w", err)
+		return nil, fmt.Errorf("failed to send sell transaction: %w", err)
 	}
 
-	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
-		if signer.PublicKey().Equals(key) {
-			return &signer
-		}
-		return nil
-	})
+	confirmation, err := b.ConfirmTransaction(sent.signature, sent.lastValidBlockHeight, b.confirmationCommitment)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign transaction: %w", err)
+		return nil, fmt.Errorf("transaction confirmation failed: %w", err)
 	}
 
-	// Send transaction
-	sig, err := b.client.SendTransaction(context.Background(), tx)
-	if err != nil {
-		return "", fmt.Errorf("failed to send sell transaction: %w", err)
-	}
+	result := &SellTokenResult{TxID: sent.signature.String(), TokenAmount: uiTokenAmount(quote.TokenAmount), RemainingTokenAmount: uiTokenAmount(balance - quote.TokenAmount), AccountClosed: closeAccount, MinSolOutput: minSolOutput, Quote: quote, Confirmation: confirmation, ComputeUnitsConsumed: sent.computeUnitsConsumed}
 
-	// Poll for transaction confirmation
-	err = b.waitForConfirmation(sig)
-	if err != nil {
-		return "", fmt.Errorf("transaction confirmation failed: %w", err)
+	// the sell landed either way, so a failed read back only leaves the quoted figures
+	if result.Fill, err = b.fillFor(sent.signature, signer.PublicKey(), mintPubKey, bondingCurvePubKey, quote, false); err != nil {
+		log.Printf("Failed to reconcile sell fill for %s: %v", sent.signature, err)
+	} else {
+		result.TokenAmount = result.Fill.UiTokenAmount()
 	}
 
-	return sig.String(), nil
+	return result, nil
 }
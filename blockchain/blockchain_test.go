@@ -1,9 +1,26 @@
 package blockchain
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
+	"github.com/ethanhosier/pumpfun-trade-bot/idl"
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/joho/godotenv"
 )
 
@@ -23,12 +40,1345 @@ func TestGetTransactionDataWithRetries(t *testing.T) {
 	t.Logf("Transaction: %+v", tx)
 }
 
-func TestGetTransaction2(t *testing.T) {
+func TestGetTransaction(t *testing.T) {
 	client := NewBlockchainClient(os.Getenv("HELIUS_API_KEY"), nil)
-	tx, err := client.getTransactionData2("2UbydyYxAmzvysksVfFmVEfLB1NawTS7GreAuAsauoh8npJSzfZukw5QyU4RQMWp5DYRzQdAF2HqURGxUEUPbUku")
+	tx, err := client.GetTransaction("2UbydyYxAmzvysksVfFmVEfLB1NawTS7GreAuAsauoh8npJSzfZukw5QyU4RQMWp5DYRzQdAF2HqURGxUEUPbUku")
 	if err != nil {
 		t.Errorf("Error getting transaction data: %v", err)
 	}
 
 	t.Logf("Transaction: %+v", tx)
 }
+
+func TestPumpEventsFromLogs(t *testing.T) {
+	mint := solana.MustPublicKeyFromBase58("Df6yfrKC8kZE3KNkrHERKzAetSxbrWeniQfyJY4Jpump")
+	user := solana.MustPublicKeyFromBase58("J4bzyKJKZKKz2HUGFiq3DMRaxEaw6MxKf8rjGTvpkqaU")
+
+	data := append([]byte{}, tradeEventDiscriminator...)
+	data = append(data, mint.Bytes()...)
+	data = binary.LittleEndian.AppendUint64(data, 50_000_000)
+	data = binary.LittleEndian.AppendUint64(data, 1_700_000_000_000)
+	data = append(data, 1)
+	data = append(data, user.Bytes()...)
+	data = binary.LittleEndian.AppendUint64(data, 1_733_000_000)
+	data = binary.LittleEndian.AppendUint64(data, 30_050_000_000)
+	data = binary.LittleEndian.AppendUint64(data, 1_071_300_000_000_000)
+
+	logs := []string{
+		"Program 6EF8rrecthR5Dkzon8Nwu78hRvfCKubJ14M5uBEwF6P invoke [1]",
+		"Program log: Instruction: Buy",
+		"Program data: " + base64.StdEncoding.EncodeToString(data),
+		"Program data: bm90IGEgcHVtcCBldmVudA==",
+	}
+
+	events, err := PumpEventsFromLogs(logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trade, ok := events.TradeBy(user.String())
+	if !ok {
+		t.Fatalf("Expected a trade by %s, got %+v", user, events)
+	}
+
+	if !trade.IsBuy || !trade.Mint.Equals(mint) || trade.SolAmount != 50_000_000 || trade.VirtualTokenReserves != 1_071_300_000_000_000 {
+		t.Errorf("Unexpected trade event: %+v", trade)
+	}
+}
+
+func TestTokenLaunchesLoop(t *testing.T) {
+	mint := solana.MustPublicKeyFromBase58("Df6yfrKC8kZE3KNkrHERKzAetSxbrWeniQfyJY4Jpump")
+	bondingCurve := solana.MustPublicKeyFromBase58("3yDrKYwVa5ezQUvBW8hFHW1TYEdXZ6QziYjze9FvWG67")
+	creator := solana.MustPublicKeyFromBase58("J4bzyKJKZKKz2HUGFiq3DMRaxEaw6MxKf8rjGTvpkqaU")
+
+	data := append([]byte{}, createEventDiscriminator...)
+	for _, s := range []string{"Test Coin", "TEST", "https://ipfs.io/ipfs/test"} {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(s)))
+		data = append(data, s...)
+	}
+	data = append(data, mint.Bytes()...)
+	data = append(data, bondingCurve.Bytes()...)
+	data = append(data, creator.Bytes()...)
+
+	programCh := make(chan WalletTransactionSignature, 2)
+	launchesCh := make(chan TokenLaunch, 1)
+	done := make(chan interface{})
+	defer close(done)
+
+	programCh <- WalletTransactionSignature{Signature: "failed", Failed: true, Logs: []string{"Program data: " + base64.StdEncoding.EncodeToString(data)}}
+	programCh <- WalletTransactionSignature{Signature: "created", Logs: []string{"Program data: " + base64.StdEncoding.EncodeToString(data)}}
+
+	go tokenLaunchesLoop(programCh, done, launchesCh)
+
+	launch := <-launchesCh
+	if launch.Signature != "created" || launch.Mint != mint.String() || launch.Creator != creator.String() || launch.Symbol != "TEST" {
+		t.Errorf("Unexpected token launch: %+v", launch)
+	}
+
+	// a consumer that has stopped reading must not keep the loop alive after shutdown
+	unreadCh := make(chan WalletTransactionSignature)
+	stopped := make(chan interface{})
+	exited := make(chan struct{})
+	go func() {
+		tokenLaunchesLoop(unreadCh, stopped, make(chan TokenLaunch))
+		close(exited)
+	}()
+	unreadCh <- WalletTransactionSignature{Signature: "unread", Logs: []string{"Program data: " + base64.StdEncoding.EncodeToString(data)}}
+	close(stopped)
+
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the loop to exit once done is closed")
+	}
+}
+
+func TestFeeEstimator(t *testing.T) {
+	server := newFakeSolanaServer(t)
+	server.setResult("getRecentPrioritizationFees", []map[string]interface{}{
+		{"slot": 1, "prioritizationFee": 0},
+		{"slot": 2, "prioritizationFee": 1_000},
+		{"slot": 3, "prioritizationFee": 5_000},
+		{"slot": 4, "prioritizationFee": 20_000},
+	})
+
+	estimator := NewFeeEstimator(server.client().client, 100, 50_000, 2)
+
+	fee, err := estimator.Estimate(75, PUMP_PROGRAM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee != 5_000 {
+		t.Errorf("Expected 75th percentile fee of 5000, got %d", fee)
+	}
+
+	if fee, _ := estimator.Estimate(0, PUMP_PROGRAM); fee != 100 {
+		t.Errorf("Expected fee clamped to minimum 100, got %d", fee)
+	}
+
+	if escalated := estimator.Escalate(5_000, 2); escalated != 20_000 {
+		t.Errorf("Expected escalated fee of 20000, got %d", escalated)
+	}
+
+	if escalated := estimator.Escalate(5_000, 10); escalated != 50_000 {
+		t.Errorf("Expected escalated fee capped at 50000, got %d", escalated)
+	}
+}
+
+func TestDecodeTransactionError(t *testing.T) {
+	signer := solana.NewWallet().PrivateKey
+	buy := solana.NewInstruction(PUMP_PROGRAM, solana.AccountMetaSlice{solana.Meta(signer.PublicKey()).SIGNER().WRITE()}, []byte{})
+	tx, err := (&BlockchainClient{}).signedTransaction([]solana.Instruction{buy}, computeUnitLimit, 100, solana.Hash{}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the two compute budget instructions come first, so the buy is instruction 2
+	err = decodeTransactionError(tx, map[string]interface{}{"InstructionError": []interface{}{2, map[string]interface{}{"Custom": 6002}}})
+	if !errors.Is(err, ErrSlippageExceeded) {
+		t.Errorf("Expected ErrSlippageExceeded, got %v", err)
+	}
+
+	var programErr *ProgramError
+	if !errors.As(err, &programErr) || programErr.Name != "TooMuchSolRequired" || !programErr.ProgramID.Equals(PUMP_PROGRAM) {
+		t.Errorf("Expected TooMuchSolRequired from the pump program, got %+v", programErr)
+	}
+
+	err = decodeTransactionError(nil, map[string]interface{}{"InstructionError": []interface{}{2, map[string]interface{}{"Custom": 6005}}})
+	if !errors.Is(err, ErrCurveComplete) {
+		t.Errorf("Expected ErrCurveComplete, got %v", err)
+	}
+
+	err = decodeTransactionError(nil, "AccountNotFound")
+	if !errors.Is(err, ErrTransactionFailed) || errors.Is(err, ErrSlippageExceeded) {
+		t.Errorf("Expected a generic ErrTransactionFailed, got %v", err)
+	}
+}
+
+func TestSimulateTransaction(t *testing.T) {
+	server := newFakeSolanaServer(t)
+	server.setResult("simulateTransaction", map[string]interface{}{
+		"context": map[string]interface{}{"slot": 1},
+		"value": map[string]interface{}{
+			"err":           map[string]interface{}{"InstructionError": []interface{}{2, map[string]interface{}{"Custom": 6003}}},
+			"logs":          []string{"Program log: Error: TooLittleSolReceived"},
+			"unitsConsumed": 42_000,
+		},
+	})
+
+	signer := solana.NewWallet().PrivateKey
+	sell := solana.NewInstruction(PUMP_PROGRAM, solana.AccountMetaSlice{solana.Meta(signer.PublicKey()).SIGNER().WRITE()}, []byte{})
+	client := server.client()
+	tx, err := client.signedTransaction([]solana.Instruction{sell}, simulationComputeUnitLimit, 100, solana.Hash{}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simulation, err := client.SimulateTransaction(tx)
+	if !errors.Is(err, ErrSlippageExceeded) {
+		t.Errorf("Expected ErrSlippageExceeded, got %v", err)
+	}
+	if simulation == nil || simulation.ComputeUnitsConsumed != 42_000 || len(simulation.Logs) != 1 {
+		t.Errorf("Unexpected simulation result: %+v", simulation)
+	}
+
+	if limit := computeUnitLimitFor(42_000); limit != 50_400 {
+		t.Errorf("Expected compute unit limit of 50400, got %d", limit)
+	}
+}
+
+func TestBundleSender(t *testing.T) {
+	rpcServer := newFakeSolanaServer(t)
+	rpcServer.setResult("simulateTransaction", map[string]interface{}{
+		"context": map[string]interface{}{"slot": 1},
+		"value":   map[string]interface{}{"err": nil, "logs": []string{}, "unitsConsumed": 30_000},
+	})
+
+	blockEngine := newFakeSolanaServer(t)
+	blockEngine.setResult("sendBundle", "bundle-1")
+	blockEngine.setResult("getInflightBundleStatuses", map[string]interface{}{
+		"context": map[string]interface{}{"slot": 10},
+		"value":   []map[string]interface{}{{"bundle_id": "bundle-1", "status": BundleLanded, "landed_slot": 9}},
+	})
+
+	tipAccount := solana.NewWallet().PublicKey()
+	sender := NewBundleSender(blockEngine.URL, tipAccount, 10_000)
+
+	client := rpcServer.client()
+	client.SetTxSender(sender)
+
+	signer := solana.NewWallet().PrivateKey
+	buy := solana.NewInstruction(PUMP_PROGRAM, solana.AccountMetaSlice{solana.Meta(signer.PublicKey()).SIGNER().WRITE()}, []byte{})
+	sent, err := client.signAndSend([]solana.Instruction{buy}, 100, &rpc.LatestBlockhashResult{LastValidBlockHeight: 100}, signer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rpcServer.lastParams("sendTransaction") != nil {
+		t.Errorf("Expected nothing sent through the RPC node")
+	}
+
+	var params []json.RawMessage
+	var encoded []string
+	if err := json.Unmarshal(blockEngine.lastParams("sendBundle"), &params); err != nil || len(params) != 2 || json.Unmarshal(params[0], &encoded) != nil || len(encoded) != 1 {
+		t.Fatalf("Unexpected sendBundle params: %s", blockEngine.lastParams("sendBundle"))
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(encoded[0])
+	tx, err := solana.TransactionFromBytes(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tx.Signatures[0].Equals(sent.signature) {
+		t.Errorf("Expected bundled transaction %s, got %s", sent.signature, tx.Signatures[0])
+	}
+
+	// compute limit, compute price, buy, tip
+	tip := tx.Message.Instructions[len(tx.Message.Instructions)-1]
+	tipProgram, _ := tx.Message.Program(tip.ProgramIDIndex)
+	if len(tx.Message.Instructions) != 4 || !tipProgram.Equals(SYSTEM_PROGRAM) || !tx.Message.AccountKeys[tip.Accounts[1]].Equals(tipAccount) {
+		t.Errorf("Expected the transaction to end with a tip to %s", tipAccount)
+	}
+
+	bundleID, ok := sender.BundleID(sent.signature)
+	if !ok || bundleID != "bundle-1" {
+		t.Errorf("Expected bundle-1 tracked for the signature, got %q", bundleID)
+	}
+
+	status, err := sender.BundleStatus(bundleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != BundleLanded || status.LandedSlot != 9 {
+		t.Errorf("Expected bundle landed in slot 9, got %+v", status)
+	}
+	if _, ok := sender.BundleID(sent.signature); ok {
+		t.Errorf("Expected the landed bundle to be forgotten")
+	}
+
+	// sending again drops bundles whose blockhash has long expired
+	stale := solana.Signature{1}
+	sender.bundles[stale] = sentBundle{id: "bundle-0", sentAt: time.Now().Add(-2 * bundleExpiry)}
+	if _, err := sender.Send(tx, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sender.BundleID(stale); ok {
+		t.Errorf("Expected the expired bundle to be forgotten")
+	}
+}
+
+func TestTxSenderSurvivesSetEndpoints(t *testing.T) {
+	client := NewBlockchainClient("", nil)
+	sender := NewBundleSender("http://block-engine", solana.NewWallet().PublicKey(), 10_000)
+
+	client.SetTxSender(sender)
+	client.SetEndpoints([]string{"http://fallback"}, []string{"http://send-only"})
+
+	if client.txSender != sender {
+		t.Errorf("Expected the bundle sender to be kept, got %T", client.txSender)
+	}
+}
+
+func TestEndpointFailover(t *testing.T) {
+	primary := newFakeSolanaServer(t)
+	primary.Close() // every call to the primary now fails to connect
+
+	fallback := newFakeSolanaServer(t)
+	fallback.setResult("getBalance", map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": 5})
+	fallback.setResult("sendTransaction", solana.Signature{7}.String())
+
+	client := fallback.client()
+	client.feeEstimator = NewFeeEstimator(nil, minPriorityFee, maxPriorityFee, feeEscalationMultiplier)
+	endpoints := []*endpoint{newEndpoint(primary.URL), newEndpoint(fallback.URL)}
+	client.setEndpoints(endpoints, endpoints)
+
+	balance, err := client.client.GetBalance(context.Background(), solana.NewWallet().PublicKey(), rpc.CommitmentProcessed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Value != 5 {
+		t.Errorf("Expected balance 5 from the fallback, got %d", balance.Value)
+	}
+
+	sig, err := client.txSender.Send(&solana.Transaction{Signatures: []solana.Signature{{7}}, Message: solana.Message{}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sig.Equals(solana.Signature{7}) {
+		t.Errorf("Expected the fallback to accept the broadcast, got %s", sig)
+	}
+
+	// the failed send to the primary may still be in flight
+	deadline := time.Now().Add(time.Second)
+	for client.EndpointStats()[0].SendErrors == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := client.EndpointStats()
+	if stats[0].ReadErrors != 1 || stats[0].SendErrors != 1 {
+		t.Errorf("Expected one read and one send error on the primary, got %+v", stats[0])
+	}
+	if stats[1].Reads != 1 || stats[1].ReadErrors != 0 || stats[1].Sends != 1 || stats[1].SendErrors != 0 {
+		t.Errorf("Expected one clean read and send on the fallback, got %+v", stats[1])
+	}
+
+	// the primary is now cooling down, so reads go straight to the fallback
+	if ordered := newEndpointPool(endpoints).ordered(); ordered[0] != endpoints[1] {
+		t.Errorf("Expected the failed primary to be tried last")
+	}
+}
+
+func TestEndpointPoolSkipsLaggingEndpoint(t *testing.T) {
+	primary := newFakeSolanaServer(t)
+	primary.setResult("getSlot", 100)
+	fallback := newFakeSolanaServer(t)
+	fallback.setResult("getSlot", 200)
+
+	endpoints := []*endpoint{newEndpoint(primary.URL), newEndpoint(fallback.URL)}
+	pool := newEndpointPool(endpoints)
+	pool.checkSlots()
+
+	if ordered := pool.ordered(); ordered[0] != endpoints[1] {
+		t.Errorf("Expected reads to skip the primary lagging by 100 slots")
+	}
+
+	fallback.setResult("getSlot", 120)
+	pool.checkSlots()
+
+	if ordered := pool.ordered(); ordered[0] != endpoints[0] {
+		t.Errorf("Expected the primary back first within %d slots", pool.maxSlotLag)
+	}
+}
+
+func TestBondingCurveAddressesFor(t *testing.T) {
+	// pinned rather than re-derived, so a wrong seed or program changes the addresses
+	mint := "Df6yfrKC8kZE3KNkrHERKzAetSxbrWeniQfyJY4Jpump"
+	expectedBondingCurve := solana.MustPublicKeyFromBase58("5qM9aAZtM6mCk7dHRL6Frr6oqmKr5hGbaTgFFb9FLUSh")
+	expectedAssociatedBondingCurve := solana.MustPublicKeyFromBase58("3T2432TjRio2iVLcbi7MHmKLYuo8UsEQiHgJHyCQKatJ")
+
+	bondingCurve, associatedBondingCurve, err := BondingCurveAddressesFor(mint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bondingCurve.Equals(expectedBondingCurve) {
+		t.Errorf("Expected bonding curve %s, got %s", expectedBondingCurve, bondingCurve)
+	}
+	if !associatedBondingCurve.Equals(expectedAssociatedBondingCurve) {
+		t.Errorf("Expected associated bonding curve %s, got %s", expectedAssociatedBondingCurve, associatedBondingCurve)
+	}
+
+	if _, _, err := BondingCurveAddressesFor("not a mint"); err == nil {
+		t.Errorf("Expected an invalid mint to fail")
+	}
+}
+
+func TestBlockhashCache(t *testing.T) {
+	server := newFakeSolanaServer(t)
+	setLatest := func(hash solana.Hash, height uint64) {
+		server.setResult("getLatestBlockhash", map[string]interface{}{
+			"context": map[string]interface{}{"slot": 1},
+			"value":   map[string]interface{}{"blockhash": hash.String(), "lastValidBlockHeight": height + 150},
+		})
+		server.setResult("getBlockHeight", height)
+	}
+	setLatest(solana.Hash{1}, 100)
+
+	cache := server.client().blockhashCache
+
+	if _, fresh := cache.BlockHeight(); fresh {
+		t.Errorf("Expected no fresh block height before the first fetch")
+	}
+
+	latest, err := cache.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !latest.Blockhash.Equals(solana.Hash{1}) || latest.LastValidBlockHeight != 250 {
+		t.Errorf("Unexpected blockhash: %+v", latest)
+	}
+
+	// served from the cache until the refresher runs
+	setLatest(solana.Hash{2}, 110)
+	if latest, _ := cache.Latest(); !latest.Blockhash.Equals(solana.Hash{1}) {
+		t.Errorf("Expected the cached blockhash, got %s", latest.Blockhash)
+	}
+
+	done := make(chan interface{})
+	defer close(done)
+	cache.Start(done)
+
+	time.Sleep(3 * cache.refreshInterval)
+	setLatest(solana.Hash{3}, 120)
+	time.Sleep(3 * cache.refreshInterval)
+
+	if latest, _ := cache.Latest(); !latest.Blockhash.Equals(solana.Hash{3}) {
+		t.Errorf("Expected the refreshed blockhash, got %s", latest.Blockhash)
+	}
+	if height, fresh := cache.BlockHeight(); !fresh || height != 120 {
+		t.Errorf("Expected fresh block height 120, got %d (fresh %v)", height, fresh)
+	}
+}
+
+func TestSellAmountQuote(t *testing.T) {
+	c := curve.NewCurve(35_000_000_000, 923_000_000_000_000, curve.DefaultFeeBasisPoints)
+	balance := uint64(40_000_000_000_000)
+
+	all, err := SellAll().quote(balance, c)
+	if err != nil || all.TokenAmount != balance {
+		t.Errorf("Expected to sell the whole balance, got %+v (%v)", all, err)
+	}
+
+	half, err := SellPercent(50).quote(balance, c)
+	if err != nil || half.TokenAmount != balance/2 {
+		t.Errorf("Expected to sell half the balance, got %+v (%v)", half, err)
+	}
+
+	exact, err := SellTokens(1_000_000).quote(balance, c)
+	if err != nil || exact.TokenAmount != 1_000_000 {
+		t.Errorf("Expected to sell exactly 1000000 tokens, got %+v (%v)", exact, err)
+	}
+
+	capital, err := SellForSol(0.5).quote(balance, c)
+	if err != nil || capital.SolAmount < 500_000_000 || capital.TokenAmount >= balance {
+		t.Errorf("Expected part of the balance to return 0.5 SOL, got %+v (%v)", capital, err)
+	}
+
+	if _, err := SellTokens(balance+1).quote(balance, c); !errors.Is(err, ErrNotEnoughTokens) {
+		t.Errorf("Expected ErrNotEnoughTokens selling more than held, got %v", err)
+	}
+
+	if _, err := SellForSol(100).quote(balance, c); err == nil {
+		t.Errorf("Expected selling for more SOL than the holding is worth to fail")
+	}
+
+	if _, err := SellPercent(150).quote(balance, c); err == nil {
+		t.Errorf("Expected an out of range percentage to fail")
+	}
+}
+
+func TestParseSellAmount(t *testing.T) {
+	expected := map[string]SellAmount{
+		"":        SellAll(),
+		"all":     SellAll(),
+		"50%":     SellPercent(50),
+		"0.05SOL": SellForSol(0.05),
+		"1000000": SellTokens(1_000_000),
+	}
+	for input, want := range expected {
+		got, err := ParseSellAmount(input)
+		if err != nil || got != want {
+			t.Errorf("Expected %q to parse as %s, got %s (%v)", input, want, got, err)
+		}
+	}
+
+	for _, input := range []string{"150%", "-1sol", "0", "half"} {
+		if _, err := ParseSellAmount(input); err == nil {
+			t.Errorf("Expected %q to be rejected", input)
+		}
+	}
+}
+
+func TestCloseEmptyTokenAccounts(t *testing.T) {
+	server := newFakeSolanaServer(t)
+	signer := solana.NewWallet().PrivateKey
+
+	tokenAccounts := []map[string]interface{}{}
+	empty := map[solana.PublicKey]bool{}
+	for i := 0; i < 25; i++ {
+		data := make([]byte, 165)
+		copy(data[32:], signer.PublicKey().Bytes())
+		data[108] = 1 // initialized
+		if i%8 == 0 {
+			binary.LittleEndian.PutUint64(data[64:], 1_000_000)
+		}
+
+		pubkey := solana.NewWallet().PublicKey()
+		if i%8 != 0 {
+			empty[pubkey] = true
+		}
+
+		tokenAccounts = append(tokenAccounts, map[string]interface{}{
+			"pubkey": pubkey.String(),
+			"account": map[string]interface{}{
+				"lamports":   2_039_280,
+				"owner":      SYSTEM_TOKEN_PROGRAM.String(),
+				"data":       []string{base64.StdEncoding.EncodeToString(data), "base64"},
+				"executable": false,
+				"rentEpoch":  0,
+			},
+		})
+	}
+	server.setResult("getTokenAccountsByOwner", map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": tokenAccounts})
+	server.setResult("getLatestBlockhash", map[string]interface{}{
+		"context": map[string]interface{}{"slot": 1},
+		"value":   map[string]interface{}{"blockhash": solana.Hash{1}.String(), "lastValidBlockHeight": 250},
+	})
+	server.setResult("getBlockHeight", 100)
+	server.setResult("simulateTransaction", map[string]interface{}{
+		"context": map[string]interface{}{"slot": 1},
+		"value":   map[string]interface{}{"err": nil, "logs": []string{}, "unitsConsumed": 10_000},
+	})
+	server.setResult("sendTransaction", solana.Signature{9}.String())
+	server.setResult("getSignatureStatuses", map[string]interface{}{
+		"context": map[string]interface{}{"slot": 50},
+		"value":   []interface{}{map[string]interface{}{"slot": 40, "confirmations": nil, "err": nil, "confirmationStatus": "finalized"}},
+	})
+
+	// housekeeping skips the bundle sender and its tip
+	blockEngine := newFakeSolanaServer(t)
+	blockEngine.setResult("sendBundle", "bundle-1")
+
+	client := server.client()
+	client.feeEstimator = NewFeeEstimator(client.client, minPriorityFee, maxPriorityFee, feeEscalationMultiplier)
+	client.SetTxSender(NewBundleSender(blockEngine.URL, solana.NewWallet().PublicKey(), 10_000))
+
+	accounts, err := client.EmptyTokenAccounts(signer.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != len(empty) {
+		t.Errorf("Expected %d empty token accounts, got %d", len(empty), len(accounts))
+	}
+
+	signatures, err := client.CloseEmptyTokenAccounts(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signatures) != 2 {
+		t.Errorf("Expected 21 closes split over 2 transactions, got %d", len(signatures))
+	}
+	if blockEngine.lastParams("sendBundle") != nil {
+		t.Errorf("Expected the closes sent through the RPC node, not bundled")
+	}
+
+	// the last batch holds the one close that did not fit in the first
+	var params []json.RawMessage
+	var encoded string
+	json.Unmarshal(server.lastParams("sendTransaction"), &params)
+	json.Unmarshal(params[0], &encoded)
+	raw, _ := base64.StdEncoding.DecodeString(encoded)
+	tx, err := solana.TransactionFromBytes(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closes := tx.Message.Instructions[2:]
+	if len(closes) != len(empty)-closeAccountsPerTransaction {
+		t.Errorf("Expected %d closes in the last batch, got %d", len(empty)-closeAccountsPerTransaction, len(closes))
+	}
+	for _, close := range closes {
+		if !empty[tx.Message.AccountKeys[close.Accounts[0]]] {
+			t.Errorf("Expected only empty accounts to be closed, got %s", tx.Message.AccountKeys[close.Accounts[0]])
+		}
+	}
+}
+
+// parsedTokenAccount is a jsonParsed token account as getTokenAccountsByOwner returns it
+func parsedTokenAccount(owner solana.PublicKey, mint solana.PublicKey, amount string, uiAmount float64) map[string]interface{} {
+	return map[string]interface{}{
+		"pubkey": solana.NewWallet().PublicKey().String(),
+		"account": map[string]interface{}{
+			"lamports": 2_039_280,
+			"owner":    SYSTEM_TOKEN_PROGRAM.String(),
+			"data": map[string]interface{}{
+				"program": "spl-token",
+				"space":   165,
+				"parsed": map[string]interface{}{
+					"type": "account",
+					"info": map[string]interface{}{
+						"mint":        mint.String(),
+						"owner":       owner.String(),
+						"tokenAmount": map[string]interface{}{"amount": amount, "decimals": 6, "uiAmount": uiAmount},
+					},
+				},
+			},
+			"executable": false,
+			"rentEpoch":  0,
+		},
+	}
+}
+
+func testAccount(owner solana.PublicKey, data []byte) map[string]interface{} {
+	return map[string]interface{}{"lamports": 1, "owner": owner.String(), "data": []string{base64.StdEncoding.EncodeToString(data), "base64"}, "executable": false, "rentEpoch": 0}
+}
+
+func testGlobalData() []byte {
+	global := append([]byte{}, curve.AccountDiscriminator("Global")...)
+	global = append(global, make([]byte, 1+2*32)...)
+	for _, v := range []uint64{0, 0, 0, 0, curve.DefaultFeeBasisPoints} {
+		global = binary.LittleEndian.AppendUint64(global, v)
+	}
+	return global
+}
+
+func testBondingCurveData(virtualTokenReserves uint64, virtualSolReserves uint64) []byte {
+	bondingCurve := append([]byte{}, curve.AccountDiscriminator("BondingCurve")...)
+	for _, v := range []uint64{virtualTokenReserves, virtualSolReserves, 0, 0, 0} {
+		bondingCurve = binary.LittleEndian.AppendUint64(bondingCurve, v)
+	}
+	return append(bondingCurve, 0)
+}
+
+func TestPortfolio(t *testing.T) {
+	server := newFakeSolanaServer(t)
+	wallet := solana.NewWallet().PublicKey()
+	pumpMint, otherMint := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	server.setResult("getBalance", map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": 1_500_000_000})
+	server.setResult("getTokenAccountsByOwner", map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": []interface{}{
+		parsedTokenAccount(wallet, otherMint, "5000000", 5),
+		parsedTokenAccount(wallet, solana.NewWallet().PublicKey(), "0", 0),
+		parsedTokenAccount(wallet, pumpMint, "10000000000000", 10_000_000),
+	}})
+
+	// accounts are requested as global, then one bonding curve per holding in token account order
+	server.setResult("getMultipleAccounts", map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": []interface{}{
+		testAccount(PUMP_PROGRAM, testGlobalData()),
+		nil,
+		testAccount(PUMP_PROGRAM, testBondingCurveData(1_000_000_000_000_000, 32_000_000_000)),
+	}})
+
+	portfolio, err := server.client().Portfolio(wallet.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if portfolio.SolBalance != 1.5 || len(portfolio.Holdings) != 2 {
+		t.Fatalf("Expected 1.5 SOL and two holdings, got %+v", portfolio)
+	}
+
+	pump := portfolio.Holdings[0]
+	expected, _ := curve.NewCurve(32_000_000_000, 1_000_000_000_000_000, curve.DefaultFeeBasisPoints).SellExactTokensIn(10_000_000_000_000)
+	if pump.Mint != pumpMint.String() || pump.BondingCurve == "" || pump.ValueInSol != float64(expected.SolAmount)/lamportsPerSol {
+		t.Errorf("Expected the pump holding valued from its curve first, got %+v", pump)
+	}
+
+	other := portfolio.Holdings[1]
+	if other.Mint != otherMint.String() || other.BondingCurve != "" || other.ValueInSol != 0 || other.Decimals != 6 {
+		t.Errorf("Expected the other holding unvalued, got %+v", other)
+	}
+
+	if total := portfolio.TotalValueInSol(); total != 1.5+pump.ValueInSol {
+		t.Errorf("Expected total of %v SOL, got %v", 1.5+pump.ValueInSol, total)
+	}
+}
+
+func TestPortfolioBatchesBondingCurves(t *testing.T) {
+	server := newFakeSolanaServer(t)
+	wallet := solana.NewWallet().PublicKey()
+
+	holdings := []interface{}{}
+	for i := 0; i < 2*maxAccountsPerRequest+5; i++ {
+		holdings = append(holdings, parsedTokenAccount(wallet, solana.NewWallet().PublicKey(), "1000000", 1))
+	}
+	server.setResult("getBalance", map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": 0})
+	server.setResult("getTokenAccountsByOwner", map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": holdings})
+
+	calls := 0
+	server.setHandler("getMultipleAccounts", func(params json.RawMessage) interface{} {
+		calls++
+		var request []json.RawMessage
+		var keys []string
+		json.Unmarshal(params, &request)
+		json.Unmarshal(request[0], &keys)
+		if len(keys) > maxAccountsPerRequest {
+			t.Errorf("Expected at most %d accounts per request, got %d", maxAccountsPerRequest, len(keys))
+		}
+
+		accounts := []interface{}{}
+		for _, key := range keys {
+			if key == PUMP_GLOBAL.String() {
+				accounts = append(accounts, testAccount(PUMP_PROGRAM, testGlobalData()))
+			} else {
+				accounts = append(accounts, testAccount(PUMP_PROGRAM, testBondingCurveData(1_000_000_000_000_000, 32_000_000_000)))
+			}
+		}
+		return map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": accounts}
+	})
+
+	portfolio, err := server.client().Portfolio(wallet.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Errorf("Expected the global and %d bonding curves in 3 requests, got %d", len(holdings), calls)
+	}
+	for _, holding := range portfolio.Holdings {
+		if holding.BondingCurve == "" || holding.ValueInSol == 0 {
+			t.Fatalf("Expected every holding valued from its curve, got %+v", holding)
+		}
+	}
+}
+
+func TestSigners(t *testing.T) {
+	key := solana.NewWallet().PrivateKey
+	dir := t.TempDir()
+
+	keypairFile := dir + "/id.json"
+	keypair, _ := json.Marshal([]byte(key))
+	os.WriteFile(keypairFile, keypair, 0600)
+
+	keystoreFile := dir + "/wallet.keystore"
+	if err := writeKeystore(keystoreFile, key, "correct horse", 1<<10); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TEST_WALLET_PRIVATE_KEY", key.String())
+
+	loaders := map[string]func() (Signer, error){
+		"base58":  func() (Signer, error) { return SignerFromBase58(key.String()) },
+		"env":     func() (Signer, error) { return SignerFromEnv("TEST_WALLET_PRIVATE_KEY") },
+		"keypair": func() (Signer, error) { return SignerFromKeypairFile(keypairFile) },
+		"keystore": func() (Signer, error) {
+			return SignerFromKeystore(keystoreFile, "correct horse")
+		},
+	}
+
+	message := []byte("message")
+	expected, _ := key.Sign(message)
+
+	for name, load := range loaders {
+		signer, err := load()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if !signer.PublicKey().Equals(key.PublicKey()) {
+			t.Errorf("%s: Expected public key %s, got %s", name, key.PublicKey(), signer.PublicKey())
+		}
+		if signature, err := signer.Sign(message); err != nil || !signature.Equals(expected) {
+			t.Errorf("%s: Expected signature %s, got %s (%v)", name, expected, signature, err)
+		}
+		if fmt.Sprint(signer) != key.PublicKey().String() {
+			t.Errorf("%s: Expected the signer to print as its public key", name)
+		}
+	}
+
+	if _, err := SignerFromKeystore(keystoreFile, "wrong"); err == nil {
+		t.Errorf("Expected the wrong passphrase to fail")
+	}
+	if _, err := SignerFromEnv("TEST_WALLET_UNSET"); err == nil {
+		t.Errorf("Expected an unset env var to fail")
+	}
+	if err := writeKeystore(dir+"/empty.keystore", key, "", 1<<10); err == nil {
+		t.Errorf("Expected an empty passphrase to be refused")
+	}
+}
+
+func TestLookupTable(t *testing.T) {
+	server := newFakeSolanaServer(t)
+	signer := solana.NewWallet().PrivateKey
+	table := solana.NewWallet().PublicKey()
+
+	var state bytes.Buffer
+	err := addresslookuptable.AddressLookupTableState{
+		TypeIndex:        1,
+		DeactivationSlot: math.MaxUint64,
+		Authority:        &table,
+		Addresses:        PumpLookupTableAddresses(),
+	}.MarshalWithEncoder(bin.NewBinEncoder(&state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.setResult("getAccountInfo", map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": map[string]interface{}{
+		"lamports": 1, "owner": ADDRESS_LOOKUP_TABLE_PROGRAM.String(), "data": []string{base64.StdEncoding.EncodeToString(state.Bytes()), "base64"}, "executable": false, "rentEpoch": 0,
+	}})
+
+	client := server.client()
+	if err := client.SetLookupTable(table); err != nil {
+		t.Fatal(err)
+	}
+
+	mint, bondingCurve, associatedBondingCurve, ata := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	buy, err := buyInstructionFrom(mint, bondingCurve, associatedBondingCurve, ata, signer.PublicKey(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := client.signedTransaction([]solana.Instruction{buy}, computeUnitLimit, 100, solana.Hash{}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tx.Message.GetVersion() != solana.MessageVersionV0 {
+		t.Errorf("Expected a v0 transaction, got version %d", tx.Message.GetVersion())
+	}
+	lookups := tx.Message.GetAddressTableLookups()
+	if len(lookups) != 1 || !lookups[0].AccountKey.Equals(table) {
+		t.Fatalf("Expected one lookup into %s, got %v", table, lookups)
+	}
+	// every table address but the associated token program, which only sells reference
+	if looked := len(lookups[0].WritableIndexes) + len(lookups[0].ReadonlyIndexes); looked != len(PumpLookupTableAddresses())-1 {
+		t.Errorf("Expected %d accounts looked up, got %d", len(PumpLookupTableAddresses())-1, looked)
+	}
+	for _, key := range tx.Message.AccountKeys {
+		if key.Equals(PUMP_GLOBAL) || key.Equals(PUMP_FEE) {
+			t.Errorf("Expected %s to be looked up rather than a static key", key)
+		}
+	}
+
+	// the signature must cover the versioned encoding
+	message, _ := tx.Message.MarshalBinary()
+	if !tx.Signatures[0].Verify(signer.PublicKey(), message) {
+		t.Errorf("Expected a valid signature over the v0 message")
+	}
+
+	encoded, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, _ := (&BlockchainClient{}).signedTransaction([]solana.Instruction{buy}, computeUnitLimit, 100, solana.Hash{}, signer)
+	legacyEncoded, _ := legacy.MarshalBinary()
+	if len(encoded) >= len(legacyEncoded) {
+		t.Errorf("Expected the v0 transaction to be smaller than the legacy one, got %d vs %d bytes", len(encoded), len(legacyEncoded))
+	}
+}
+
+func TestParseTransaction(t *testing.T) {
+	signer := solana.NewWallet().PrivateKey
+	table := solana.NewWallet().PublicKey()
+	mint, bondingCurve, associatedBondingCurve, ata := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	client := &BlockchainClient{lookupTables: map[solana.PublicKey]solana.PublicKeySlice{table: PumpLookupTableAddresses()}}
+	buy, err := buyInstructionFrom(mint, bondingCurve, associatedBondingCurve, ata, signer.PublicKey(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := client.signedTransaction([]solana.Instruction{buy}, computeUnitLimit, 100, solana.Hash{}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := tx.MarshalBinary()
+
+	// the node reports loaded keys in lookup order, writable ones first
+	loaded := map[string][]string{}
+	for _, lookup := range tx.Message.GetAddressTableLookups() {
+		for _, index := range lookup.WritableIndexes {
+			loaded["writable"] = append(loaded["writable"], PumpLookupTableAddresses()[index].String())
+		}
+		for _, index := range lookup.ReadonlyIndexes {
+			loaded["readonly"] = append(loaded["readonly"], PumpLookupTableAddresses()[index].String())
+		}
+	}
+	accountCount := len(tx.Message.AccountKeys) + len(loaded["writable"]) + len(loaded["readonly"])
+
+	indexOf := func(key solana.PublicKey) int {
+		for i, k := range tx.Message.AccountKeys {
+			if k.Equals(key) {
+				return i
+			}
+		}
+		t.Fatalf("%s is not a static key", key)
+		return 0
+	}
+	pre, post := make([]uint64, accountCount), make([]uint64, accountCount)
+	pre[0], post[0] = 2_000_000_000, 1_489_995_000
+	pre[indexOf(bondingCurve)], post[indexOf(bondingCurve)] = 30_000_000_000, 30_500_000_000
+
+	tokenBalance := func(account solana.PublicKey, owner solana.PublicKey, amount string) map[string]interface{} {
+		return map[string]interface{}{
+			"accountIndex":  indexOf(account),
+			"mint":          mint.String(),
+			"owner":         owner.String(),
+			"uiTokenAmount": map[string]interface{}{"amount": amount, "decimals": 6},
+		}
+	}
+
+	response, _ := json.Marshal(map[string]interface{}{
+		"slot":        42,
+		"blockTime":   1_700_000_000,
+		"version":     0,
+		"transaction": []string{base64.StdEncoding.EncodeToString(encoded), "base64"},
+		"meta": map[string]interface{}{
+			"err":                  nil,
+			"fee":                  5_000,
+			"computeUnitsConsumed": 40_000,
+			"preBalances":          pre,
+			"postBalances":         post,
+			"loadedAddresses":      loaded,
+			"logMessages":          []string{"Program log: Instruction: Buy"},
+			"innerInstructions": []interface{}{map[string]interface{}{
+				"index": len(tx.Message.Instructions) - 1,
+				"instructions": []interface{}{map[string]interface{}{
+					"programIdIndex": indexOf(PUMP_PROGRAM),
+					"accounts":       []int{accountCount - 1},
+					"data":           "",
+				}},
+			}},
+			"preTokenBalances": []interface{}{
+				tokenBalance(associatedBondingCurve, bondingCurve, "1000000000000"),
+			},
+			"postTokenBalances": []interface{}{
+				tokenBalance(associatedBondingCurve, bondingCurve, "990000000000"),
+				tokenBalance(ata, signer.PublicKey(), "10000000000"),
+			},
+		},
+	})
+	var result rpc.GetTransactionResult
+	if err := json.Unmarshal(response, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := parseTransaction(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !parsed.Signature.Equals(tx.Signatures[0]) || !parsed.FeePayer.Equals(signer.PublicKey()) || parsed.Slot != 42 || parsed.Failed() {
+		t.Errorf("Expected the signature, payer, slot and success to carry over, got %+v", parsed)
+	}
+	if len(parsed.AccountKeys) != accountCount {
+		t.Errorf("Expected %d resolved account keys, got %d", accountCount, len(parsed.AccountKeys))
+	}
+
+	pump := parsed.Instructions[len(parsed.Instructions)-1]
+	if !pump.ProgramID.Equals(PUMP_PROGRAM) || fmt.Sprint(pump.Accounts) != fmt.Sprint(solana.AccountMetaSlice(buy.Accounts()).GetKeys()) {
+		t.Errorf("Expected the buy's accounts resolved through the lookup table, got %v", pump.Accounts)
+	}
+	if len(parsed.InstructionsFor(PUMP_PROGRAM)) != 2 {
+		t.Errorf("Expected the top level and inner pump instructions, got %d", len(parsed.InstructionsFor(PUMP_PROGRAM)))
+	}
+
+	if delta := parsed.SolDelta(signer.PublicKey()); delta != -510_005_000 {
+		t.Errorf("Expected the payer to spend 510005000 lamports, got %d", delta)
+	}
+	if delta := parsed.SolDelta(bondingCurve); delta != 500_000_000 {
+		t.Errorf("Expected the curve to gain 500000000 lamports, got %d", delta)
+	}
+	if delta := parsed.TokenDelta(signer.PublicKey(), mint); delta != 10_000_000_000 {
+		t.Errorf("Expected the buyer to gain 10000000000 tokens, got %d", delta)
+	}
+	if delta := parsed.TokenDelta(bondingCurve, mint); delta != -10_000_000_000 {
+		t.Errorf("Expected the curve to lose 10000000000 tokens, got %d", delta)
+	}
+	if parsed.TokenDecimals[mint] != 6 {
+		t.Errorf("Expected 6 decimals, got %d", parsed.TokenDecimals[mint])
+	}
+}
+
+func TestClassifyTransaction(t *testing.T) {
+	leader := solana.MustPublicKeyFromBase58("8sDpg1Y52oJkkdemxTUgr3R3k3v6mXZsz5UyAubQ5Usg")
+	other := solana.MustPublicKeyFromBase58("9vLwTV3mN6fJ6kD7pRrYf1QF2rQy5hXbZ3mS8cT4uWnE")
+	migrationAuthority := solana.MustPublicKeyFromBase58("JtqrawtNE59pQLP1F9jFCvWaPSu2uip2DnZrWaZNU9m")
+	mint := solana.MustPublicKeyFromBase58("Bqf1f4YEpkZM8AdqZnEjT1VPcnFGVKpzVoaQkJeP67Ky")
+
+	tests := []struct {
+		fixture  string
+		wallet   solana.PublicKey
+		expected Classification
+	}{
+		{"pump_buy", leader, Classification{Kind: PumpBuyTransaction, Mint: mint, SolAmount: 1_000_000_000, TokenAmount: 35_000_000_000_000, Succeeded: true}},
+		// no events, so the amounts are the ones the buy asked for
+		{"pump_buy_failed", leader, Classification{Kind: PumpBuyTransaction, Mint: mint, SolAmount: 1_050_000_000, TokenAmount: 35_000_000_000_000, Succeeded: false}},
+		{"pump_sell", leader, Classification{Kind: PumpSellTransaction, Mint: mint, SolAmount: 1_000_000_000, TokenAmount: 35_000_000_000_000, Succeeded: true}},
+		{"create", leader, Classification{Kind: CreateTransaction, Mint: mint, SolAmount: 1_000_000_000, TokenAmount: 35_000_000_000_000, Succeeded: true}},
+		{"transfer", leader, Classification{Kind: TransferTransaction, Mint: mint, SolAmount: 250_000_000, TokenAmount: 5_000_000_000, Succeeded: true}},
+		{"migration", migrationAuthority, Classification{Kind: MigrationTransaction, Mint: mint, Succeeded: true}},
+		// a v0 transaction whose buy is an inner instruction of an aggregator
+		{"router_buy", leader, Classification{Kind: PumpBuyTransaction, Mint: mint, SolAmount: 1_000_000_000, TokenAmount: 35_000_000_000_000, Succeeded: true}},
+		{"other", leader, Classification{Kind: OtherTransaction, Succeeded: true}},
+		// someone else's buy that only lists the leader as an extra account
+		{"pump_buy_by_other", leader, Classification{Kind: OtherTransaction, Succeeded: true}},
+		{"pump_buy_by_other", other, Classification{Kind: PumpBuyTransaction, Mint: mint, SolAmount: 1_000_000_000, TokenAmount: 35_000_000_000_000, Succeeded: true}},
+	}
+
+	for _, test := range tests {
+		data, err := os.ReadFile("testdata/transactions/" + test.fixture + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result rpc.GetTransactionResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatalf("%s: %v", test.fixture, err)
+		}
+		tx, err := parseTransaction(&result)
+		if err != nil {
+			t.Fatalf("%s: %v", test.fixture, err)
+		}
+
+		classification := ClassifyTransaction(tx, test.wallet)
+		test.expected.Signature, test.expected.Wallet = tx.Signature, test.wallet
+		if classification != test.expected {
+			t.Errorf("%s: Expected %+v, got %+v", test.fixture, test.expected, classification)
+		}
+	}
+}
+
+func TestFillFrom(t *testing.T) {
+	leader := solana.MustPublicKeyFromBase58("8sDpg1Y52oJkkdemxTUgr3R3k3v6mXZsz5UyAubQ5Usg")
+	mint := solana.MustPublicKeyFromBase58("Bqf1f4YEpkZM8AdqZnEjT1VPcnFGVKpzVoaQkJeP67Ky")
+	bondingCurve := solana.MustPublicKeyFromBase58("7WQY8uArFX4BTShb2kHekY9hXJRygtYT4btMyTxhTt5s")
+
+	fixture := func(name string) json.RawMessage {
+		data, err := os.ReadFile("testdata/transactions/" + name + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	// read back through the client, as a buy does once it is confirmed
+	server := newFakeSolanaServer(t)
+	server.setResult("getTransaction", fixture("pump_buy"))
+	fill, err := server.client().fillFor(solana.Signature{}, leader, mint, bondingCurve, &curve.Quote{SolAmount: 990_000_000, TokenAmount: 35_000_000_000_000}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Fill{Slot: 301_234_567, TokenAmount: 35_000_000_000_000, SolAmount: 1_000_000_000, NetworkFee: 5_000, SlippageBps: 101}
+	if *fill != expected {
+		t.Errorf("Expected buy fill %+v, got %+v", expected, *fill)
+	}
+
+	parse := func(name string) *ParsedTransaction {
+		var result rpc.GetTransactionResult
+		if err := json.Unmarshal(fixture(name), &result); err != nil {
+			t.Fatal(err)
+		}
+		tx, err := parseTransaction(&result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	fill, err = fillFrom(parse("pump_sell"), leader, mint, bondingCurve, &curve.Quote{SolAmount: 1_010_000_000, TokenAmount: 35_000_000_000_000}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = Fill{Slot: 301_234_567, TokenAmount: 35_000_000_000_000, SolAmount: 1_000_000_000, NetworkFee: 5_000, SlippageBps: 99}
+	if *fill != expected {
+		t.Errorf("Expected sell fill %+v, got %+v", expected, *fill)
+	}
+
+	if _, err := fillFrom(parse("pump_buy_failed"), leader, mint, bondingCurve, nil, true); err == nil {
+		t.Errorf("Expected a failed transaction to have no fill")
+	}
+	if _, err := fillFrom(parse("pump_sell"), leader, mint, bondingCurve, nil, true); err == nil {
+		t.Errorf("Expected a sell not to reconcile as a buy")
+	}
+}
+
+func TestPumpInstructionsFromIDL(t *testing.T) {
+	mint, bondingCurve, associatedBondingCurve, ata, user := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	buy, err := buyInstructionFrom(mint, bondingCurve, associatedBondingCurve, ata, user, 1_000, 2_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sell, err := sellInstructionFrom(mint, bondingCurve, associatedBondingCurve, ata, user, 3_000, 4_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the layouts buys and sells were hand-encoded with before the IDL was bundled
+	expectedData := func(discriminator uint64, amount uint64, limit uint64) []byte {
+		data := binary.LittleEndian.AppendUint64(nil, discriminator)
+		data = binary.LittleEndian.AppendUint64(data, amount)
+		return binary.LittleEndian.AppendUint64(data, limit)
+	}
+	if data, _ := buy.Data(); !bytes.Equal(data, expectedData(16927863322537952870, 1_000, 2_000)) {
+		t.Errorf("Expected the buy data layout, got %x", data)
+	}
+	if data, _ := sell.Data(); !bytes.Equal(data, expectedData(12502976635542562355, 3_000, 4_000)) {
+		t.Errorf("Expected the sell data layout, got %x", data)
+	}
+
+	expectedBuyAccounts := []solana.PublicKey{PUMP_GLOBAL, PUMP_FEE, mint, bondingCurve, associatedBondingCurve, ata, user, SYSTEM_PROGRAM, SYSTEM_TOKEN_PROGRAM, SYSTEM_RENT, PUMP_EVENT_AUTHORITY, PUMP_PROGRAM}
+	expectedSellAccounts := []solana.PublicKey{PUMP_GLOBAL, PUMP_FEE, mint, bondingCurve, associatedBondingCurve, ata, user, SYSTEM_PROGRAM, SYSTEM_ASSOCIATED_TOKEN_ACCOUNT_PROGRAM, SYSTEM_TOKEN_PROGRAM, PUMP_EVENT_AUTHORITY, PUMP_PROGRAM}
+	for name, instruction := range map[string]*solana.GenericInstruction{"buy": buy, "sell": sell} {
+		expected := expectedBuyAccounts
+		if name == "sell" {
+			expected = expectedSellAccounts
+		}
+
+		if !instruction.ProgramID().Equals(PUMP_PROGRAM) {
+			t.Errorf("Expected the %s to run pump.fun, got %s", name, instruction.ProgramID())
+		}
+		if got := solana.AccountMetaSlice(instruction.Accounts()).GetKeys(); fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("Expected %s accounts %v, got %v", name, expected, got)
+		}
+		for i, account := range instruction.Accounts() {
+			// the fee recipient, the curve and its token account, and the user's token account and wallet
+			writable := i == 1 || (i >= 3 && i <= 6)
+			if account.IsWritable != writable || account.IsSigner != (i == 6) {
+				t.Errorf("Expected %s account %d writable=%v signer=%v, got %+v", name, i, writable, i == 6, account)
+			}
+		}
+	}
+}
+
+func TestPumpInstructionsFromAnchor030IDL(t *testing.T) {
+	// the bundled IDL rewritten the way anchor 0.30 emits it, with snake case accounts
+	var legacy struct {
+		Instructions []struct {
+			Name     string `json:"name"`
+			Accounts []struct {
+				Name     string `json:"name"`
+				IsMut    bool   `json:"isMut"`
+				IsSigner bool   `json:"isSigner"`
+			} `json:"accounts"`
+			Args []json.RawMessage `json:"args"`
+		} `json:"instructions"`
+	}
+	if err := json.Unmarshal(pumpIDLJSON, &legacy); err != nil {
+		t.Fatal(err)
+	}
+	snakeCase := regexp.MustCompile("([a-z0-9])([A-Z])")
+	instructions := []map[string]interface{}{}
+	for _, instruction := range legacy.Instructions {
+		accounts := []map[string]interface{}{}
+		for _, account := range instruction.Accounts {
+			accounts = append(accounts, map[string]interface{}{
+				"name":     strings.ToLower(snakeCase.ReplaceAllString(account.Name, "${1}_${2}")),
+				"writable": account.IsMut,
+				"signer":   account.IsSigner,
+			})
+		}
+		instructions = append(instructions, map[string]interface{}{"name": instruction.Name, "accounts": accounts, "args": instruction.Args})
+	}
+	anchor030, _ := json.Marshal(map[string]interface{}{"address": PUMP_PROGRAM.String(), "instructions": instructions})
+
+	mint, bondingCurve, associatedBondingCurve, ata, user := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	expected, err := buyInstructionFrom(mint, bondingCurve, associatedBondingCurve, ata, user, 1_000, 2_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundled := pumpIDL
+	defer func() { pumpIDL = bundled }()
+	pumpIDL = idl.MustParse(anchor030)
+
+	buy, err := buyInstructionFrom(mint, bondingCurve, associatedBondingCurve, ata, user, 1_000, 2_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buy.Accounts()) != len(expected.Accounts()) {
+		t.Fatalf("Expected %d buy accounts, got %d", len(expected.Accounts()), len(buy.Accounts()))
+	}
+	for i, account := range expected.Accounts() {
+		if got := buy.Accounts()[i]; *got != *account {
+			t.Errorf("Expected buy account %d to be %+v, got %+v", i, *account, *got)
+		}
+	}
+}
+
+func TestProgramCanaryDrift(t *testing.T) {
+	global := func(feeRecipient solana.PublicKey, extra int) []byte {
+		data := append([]byte{}, curve.AccountDiscriminator("Global")...)
+		data = append(data, 1)
+		data = append(data, solana.NewWallet().PublicKey().Bytes()...)
+		data = append(data, feeRecipient.Bytes()...)
+		for _, value := range []uint64{1_073_000_000_000_000, 30_000_000_000, 793_100_000_000_000, 1_000_000_000_000_000, 100} {
+			data = binary.LittleEndian.AppendUint64(data, value)
+		}
+		return append(data, make([]byte, extra)...)
+	}
+
+	baselineSize := 0
+	if drift := globalDrift(global(PUMP_FEE, 32), &baselineSize); drift != "" || baselineSize == 0 {
+		t.Errorf("Expected the first global account to set the baseline, got %q", drift)
+	}
+	if drift := globalDrift(global(PUMP_FEE, 64), &baselineSize); !strings.Contains(drift, "resized") {
+		t.Errorf("Expected a resize to drift, got %q", drift)
+	}
+	if drift := globalDrift(global(solana.NewWallet().PublicKey(), 32), &baselineSize); !strings.Contains(drift, "fee recipient") {
+		t.Errorf("Expected a new fee recipient to drift, got %q", drift)
+	}
+	if drift := globalDrift([]byte("not the global account"), &baselineSize); drift == "" {
+		t.Errorf("Expected an undecodable global account to drift")
+	}
+
+	programData := solana.NewWallet().PublicKey()
+	program := append(binary.LittleEndian.AppendUint32(nil, upgradeableLoaderProgram), programData.Bytes()...)
+	if address, err := programDataAddressFrom(program); err != nil || !address.Equals(programData) {
+		t.Errorf("Expected program data at %s, got %s (%v)", programData, address, err)
+	}
+	slot, err := deploySlotFrom(binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint32(nil, upgradeableLoaderProgramData), 280_000_000))
+	if err != nil || slot != 280_000_000 {
+		t.Errorf("Expected deploy slot 280000000, got %d (%v)", slot, err)
+	}
+	if _, err := deploySlotFrom(program); err == nil {
+		t.Errorf("Expected a program account not to read as program data")
+	}
+
+	mint, user := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	tradeLog := func(isBuy bool) string {
+		data := append([]byte{}, tradeEventDiscriminator...)
+		data = append(data, mint.Bytes()...)
+		data = binary.LittleEndian.AppendUint64(data, 100_000)
+		data = binary.LittleEndian.AppendUint64(data, 3_000_000_000)
+		if isBuy {
+			data = append(data, 1)
+		} else {
+			data = append(data, 0)
+		}
+		data = append(data, user.Bytes()...)
+		data = binary.LittleEndian.AppendUint64(data, 1_733_000_000)
+		data = binary.LittleEndian.AppendUint64(data, 30_000_000_000)
+		data = binary.LittleEndian.AppendUint64(data, 1_073_000_000_000_000)
+		return "Program data: " + base64.StdEncoding.EncodeToString(data)
+	}
+
+	if drift, err := simulationDrift(&SimulationResult{Logs: []string{tradeLog(true), tradeLog(false)}}, nil, mint, user); drift != "" || err != nil {
+		t.Errorf("Expected a buy and sell to pass, got %q (%v)", drift, err)
+	}
+	if drift, _ := simulationDrift(&SimulationResult{Logs: []string{tradeLog(true)}}, nil, mint, user); drift == "" {
+		t.Errorf("Expected a missing sell event to drift")
+	}
+
+	// an unknown instruction in the pump program, as after an upgrade renames it
+	unknownInstruction := &ProgramError{ProgramID: PUMP_PROGRAM, Code: 101, err: ErrTransactionFailed}
+	if drift, _ := simulationDrift(nil, unknownInstruction, mint, user); drift == "" {
+		t.Errorf("Expected an anchor framework error to drift")
+	}
+	slippage := &ProgramError{ProgramID: PUMP_PROGRAM, Code: 6002, err: ErrSlippageExceeded}
+	if drift, err := simulationDrift(nil, slippage, mint, user); drift != "" || err == nil {
+		t.Errorf("Expected slippage to be inconclusive, got %q (%v)", drift, err)
+	}
+	noLamports := &ProgramError{ProgramID: SYSTEM_PROGRAM, Code: 1, err: ErrTransactionFailed}
+	if drift, err := simulationDrift(nil, noLamports, mint, user); drift != "" || err == nil {
+		t.Errorf("Expected a system program failure to be inconclusive, got %q (%v)", drift, err)
+	}
+	if drift, err := simulationDrift(nil, errors.New("connection refused"), mint, user); drift != "" || err == nil {
+		t.Errorf("Expected an rpc error to be inconclusive, got %q (%v)", drift, err)
+	}
+}
+
+func TestRaydiumPool(t *testing.T) {
+	mint := solana.NewWallet().PublicKey()
+	poolAddress, openOrders, marketID, targetOrders := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	baseVault, quoteVault := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	marketProgram := solana.MustPublicKeyFromBase58("srmqPvymJeFKQ4zGQed1GFppgkRHL9kaELCbyksJtPX")
+
+	poolData := make([]byte, raydiumPoolSize)
+	for offset, value := range map[int]uint64{176: 25, 184: 10_000, 192: 1_000_000_000, 200: 1_000_000_000} {
+		binary.LittleEndian.PutUint64(poolData[offset:], value)
+	}
+	for offset, key := range map[int]solana.PublicKey{336: baseVault, 368: quoteVault, 400: mint, 432: SOL, 496: openOrders, 528: marketID, 560: marketProgram, 592: targetOrders} {
+		copy(poolData[offset:], key.Bytes())
+	}
+
+	pool, err := decodeRaydiumPool(poolAddress, poolData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeRaydiumPool(poolAddress, poolData[:100]); err == nil {
+		t.Errorf("Expected a short account not to decode as a pool")
+	}
+
+	// the vault signer nonce is whichever first lands off the curve
+	marketData := make([]byte, openBookMarketSize)
+	var vaultSigner solana.PublicKey
+	for nonce := uint64(0); ; nonce++ {
+		binary.LittleEndian.PutUint64(marketData[45:], nonce)
+		if vaultSigner, err = solana.CreateProgramAddress([][]byte{marketID.Bytes(), marketData[45:53]}, marketProgram); err == nil {
+			break
+		}
+	}
+	bids, asks, eventQueue, marketBaseVault, marketQuoteVault := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	for offset, key := range map[int]solana.PublicKey{117: marketBaseVault, 165: marketQuoteVault, 253: eventQueue, 285: bids, 317: asks} {
+		copy(marketData[offset:], key.Bytes())
+	}
+	if err := pool.withMarket(marketData); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pnl owed to the protocol comes off both vaults before pricing
+	quoter, err := pool.quoterFor(mint, 206_901_000_000_000, 80_000_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quoter.TokenReserves != 206_900_000_000_000 || quoter.SolReserves != 79_000_000_000 || quoter.FeeNumerator != 25 || quoter.FeeDenominator != 10_000 {
+		t.Errorf("Expected the coin as base and SOL as quote less pnl, got %+v", quoter)
+	}
+	if _, err := pool.quoterFor(solana.NewWallet().PublicKey(), 1, 1); err == nil {
+		t.Errorf("Expected an error pricing a mint the pool does not hold")
+	}
+
+	user, source, destination := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	swap := raydiumSwapBaseInInstructionFrom(pool, source, destination, user, 1_000, 900)
+	data, _ := swap.Data()
+	expectedData := binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint64([]byte{raydiumSwapBaseIn}, 1_000), 900)
+	if !bytes.Equal(data, expectedData) {
+		t.Errorf("Expected swap data %x, got %x", expectedData, data)
+	}
+
+	expectedAccounts := []solana.PublicKey{SYSTEM_TOKEN_PROGRAM, poolAddress, RAYDIUM_AMM_AUTHORITY, openOrders, targetOrders, baseVault, quoteVault, marketProgram, marketID, bids, asks, eventQueue, marketBaseVault, marketQuoteVault, vaultSigner, source, destination, user}
+	if got := solana.AccountMetaSlice(swap.Accounts()).GetKeys(); fmt.Sprint(got) != fmt.Sprint(expectedAccounts) {
+		t.Errorf("Expected swap accounts %v, got %v", expectedAccounts, got)
+	}
+	if accounts := swap.Accounts(); !accounts[len(accounts)-1].IsSigner {
+		t.Errorf("Expected the user to sign the swap")
+	}
+}
+
+func TestPoolFillFrom(t *testing.T) {
+	wallet, mint := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	tx := &ParsedTransaction{
+		Slot: 301234999,
+		Fee:  5000,
+		TokenDeltas: map[solana.PublicKey]map[solana.PublicKey]int64{
+			wallet:                {mint: -10_000_000_000_000},
+			RAYDIUM_AMM_AUTHORITY: {mint: 10_000_000_000_000, SOL: -3_600_000_000},
+		},
+	}
+
+	fill, err := poolFillFrom(tx, wallet, mint, RAYDIUM_AMM_AUTHORITY, &curve.Quote{SolAmount: 3_633_000_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fill.TokenAmount != 10_000_000_000_000 || fill.SolAmount != 3_600_000_000 || fill.PumpFee != 0 || fill.NetworkFee != 5000 {
+		t.Errorf("Expected the pool's wrapped SOL outflow as the fill, got %+v", fill)
+	}
+	if fill.SlippageBps != 90 {
+		t.Errorf("Expected 90 bps of slippage, got %d", fill.SlippageBps)
+	}
+
+	tx.Err = map[string]interface{}{"InstructionError": []interface{}{1, map[string]interface{}{"Custom": 30}}}
+	if _, err := poolFillFrom(tx, wallet, mint, RAYDIUM_AMM_AUTHORITY, nil); err == nil {
+		t.Errorf("Expected an error for a failed transaction")
+	}
+}
@@ -0,0 +1,115 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethanhosier/pumpfun-trade-bot/utils"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	blockhashRefreshInterval = 2 * time.Second
+	blockhashMaxAge          = 10 * time.Second // well inside the ~60s a blockhash stays valid
+)
+
+// BlockhashCache keeps a recent blockhash and the current block height so transactions can be
+// built without a round trip. Without a running refresher it fetches whenever the cache is stale.
+type BlockhashCache struct {
+	client          *rpc.Client
+	commitment      rpc.CommitmentType
+	refreshInterval time.Duration
+	maxAge          time.Duration
+
+	mu          sync.RWMutex
+	latest      *rpc.LatestBlockhashResult
+	blockHeight uint64
+	fetchedAt   time.Time
+}
+
+func NewBlockhashCache(client *rpc.Client, commitment rpc.CommitmentType, refreshInterval time.Duration) *BlockhashCache {
+	return &BlockhashCache{
+		client:          client,
+		commitment:      commitment,
+		refreshInterval: refreshInterval,
+		maxAge:          blockhashMaxAge,
+	}
+}
+
+// Start refreshes the cache every refresh interval until done is closed
+func (c *BlockhashCache) Start(done <-chan interface{}) {
+	if err := c.Refresh(); err != nil {
+		log.Printf("Failed to refresh blockhash: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := c.Refresh(); err != nil {
+					log.Printf("Failed to refresh blockhash: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Refresh fetches the latest blockhash and block height together
+func (c *BlockhashCache) Refresh() error {
+	blockHeightTask := utils.DoAsync(func() (uint64, error) {
+		return c.client.GetBlockHeight(context.Background(), c.commitment)
+	})
+
+	latest, err := c.client.GetLatestBlockhash(context.Background(), c.commitment)
+	if err != nil {
+		return fmt.Errorf("failed to get latest blockhash: %w", err)
+	}
+
+	blockHeight, err := utils.GetAsync(blockHeightTask)
+	if err != nil {
+		return fmt.Errorf("failed to get block height: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latest = latest.Value
+	c.blockHeight = blockHeight
+	c.fetchedAt = time.Now()
+
+	return nil
+}
+
+// Latest returns the cached blockhash, fetching one first if it is missing or stale
+func (c *BlockhashCache) Latest() (*rpc.LatestBlockhashResult, error) {
+	c.mu.RLock()
+	latest, fresh := c.latest, time.Since(c.fetchedAt) < c.maxAge
+	c.mu.RUnlock()
+
+	if latest != nil && fresh {
+		return latest, nil
+	}
+
+	if err := c.Refresh(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.latest, nil
+}
+
+// BlockHeight returns the block height from the last refresh and whether it is still fresh.
+// Heights only grow, so a stale one is a lower bound.
+func (c *BlockhashCache) BlockHeight() (uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.blockHeight, c.latest != nil && time.Since(c.fetchedAt) < c.refreshInterval*2
+}
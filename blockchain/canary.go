@@ -0,0 +1,250 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	canaryBuyLamports = 100_000 // 0.0001 SOL, only ever simulated
+	canarySlippageBps = 5_000   // the curve moving between quote and simulation is not drift
+
+	// bincode tags of the upgradeable loader's account states
+	upgradeableLoaderProgram     = 2
+	upgradeableLoaderProgramData = 3
+)
+
+// ProgramCanary checks that pump.fun still matches what buys and sells are built against: the
+// program has not been redeployed, the global account keeps its layout and fee recipient, and a
+// tiny buy and sell against a known active curve still simulates
+type ProgramCanary struct {
+	client                 *BlockchainClient
+	signer                 Signer
+	mint                   solana.PublicKey
+	bondingCurve           solana.PublicKey
+	associatedBondingCurve solana.PublicKey
+
+	mu         sync.Mutex
+	deploySlot uint64 // zero until the first check takes it as the baseline
+	globalSize int    // zero until the first check takes it as the baseline
+}
+
+// NewProgramCanary trades the mint's curve in simulation as signer, which needs enough SOL to pay
+// for the buy. A zero deploySlot trusts whatever deployment the first check sees.
+func NewProgramCanary(client *BlockchainClient, signer Signer, mint string, deploySlot uint64) (*ProgramCanary, error) {
+	mintPubKey, err := solana.PublicKeyFromBase58(mint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid canary mint: %w", err)
+	}
+
+	bondingCurve, associatedBondingCurve, err := BondingCurveAddressesFor(mint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProgramCanary{
+		client:                 client,
+		signer:                 signer,
+		mint:                   mintPubKey,
+		bondingCurve:           bondingCurve,
+		associatedBondingCurve: associatedBondingCurve,
+		deploySlot:             deploySlot,
+	}, nil
+}
+
+// Check runs every check, returning an ErrProgramDrift listing what changed, or the errors of any
+// checks that could not finish
+func (c *ProgramCanary) Check() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	drifts, errs := []string{}, []error{}
+	for _, check := range []func() (string, error){c.checkDeploySlot, c.checkGlobal, c.checkTrade} {
+		drift, err := check()
+		if drift != "" {
+			drifts = append(drifts, drift)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(drifts) > 0 {
+		return fmt.Errorf("%w: %s", ErrProgramDrift, strings.Join(drifts, "; "))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ProgramCanary) checkDeploySlot() (string, error) {
+	program, err := c.client.accountsData(PUMP_PROGRAM)
+	if err != nil {
+		return "", fmt.Errorf("failed to get pump program account: %w", err)
+	}
+
+	programData, err := programDataAddressFrom(program[0])
+	if err != nil {
+		return err.Error(), nil
+	}
+
+	data, err := c.client.accountsData(programData)
+	if err != nil {
+		return "", fmt.Errorf("failed to get pump program data account: %w", err)
+	}
+
+	slot, err := deploySlotFrom(data[0])
+	if err != nil {
+		return err.Error(), nil
+	}
+
+	if c.deploySlot == 0 {
+		c.deploySlot = slot
+		return "", nil
+	}
+	if slot != c.deploySlot {
+		return fmt.Sprintf("program redeployed at slot %d, expected slot %d", slot, c.deploySlot), nil
+	}
+	return "", nil
+}
+
+// programDataAddressFrom reads where an upgradeable program keeps its code and deployment slot
+func programDataAddressFrom(data []byte) (solana.PublicKey, error) {
+	if len(data) < 4+32 || binary.LittleEndian.Uint32(data) != upgradeableLoaderProgram {
+		return solana.PublicKey{}, fmt.Errorf("pump program is no longer an upgradeable program")
+	}
+	return solana.PublicKeyFromBytes(data[4:36]), nil
+}
+
+func deploySlotFrom(data []byte) (uint64, error) {
+	if len(data) < 4+8 || binary.LittleEndian.Uint32(data) != upgradeableLoaderProgramData {
+		return 0, fmt.Errorf("pump program data account has an unknown layout")
+	}
+	return binary.LittleEndian.Uint64(data[4:12]), nil
+}
+
+func (c *ProgramCanary) checkGlobal() (string, error) {
+	data, err := c.client.accountsData(PUMP_GLOBAL)
+	if err != nil {
+		return "", fmt.Errorf("failed to get global account: %w", err)
+	}
+
+	return globalDrift(data[0], &c.globalSize), nil
+}
+
+// globalDrift describes how the global account no longer matches what trades are built with,
+// taking its size as the baseline the first time it is seen
+func globalDrift(data []byte, baselineSize *int) string {
+	global, err := curve.DecodeGlobal(data)
+	if err != nil {
+		return fmt.Sprintf("global account no longer decodes: %v", err)
+	}
+
+	drifts := []string{}
+	if !global.Initialized {
+		drifts = append(drifts, "global account is not initialized")
+	}
+	if !global.FeeRecipient.Equals(PUMP_FEE) {
+		drifts = append(drifts, fmt.Sprintf("fee recipient changed to %s", global.FeeRecipient))
+	}
+
+	if *baselineSize == 0 {
+		*baselineSize = len(data)
+	} else if len(data) != *baselineSize {
+		drifts = append(drifts, fmt.Sprintf("global account resized from %d to %d bytes", *baselineSize, len(data)))
+	}
+
+	return strings.Join(drifts, "; ")
+}
+
+// checkTrade simulates buying a little of the canary curve and selling it straight back in one
+// transaction, so the signer needs no tokens
+func (c *ProgramCanary) checkTrade() (string, error) {
+	_, bondingCurve, err := c.client.curveFor(c.bondingCurve)
+	if err != nil {
+		return "", fmt.Errorf("canary curve unusable: %w", err)
+	}
+
+	quote, err := bondingCurve.BuyExactSolIn(canaryBuyLamports)
+	if err != nil {
+		return "", fmt.Errorf("failed to quote canary buy: %w", err)
+	}
+
+	ata, ataCreateInstruction, err := c.client.getOrCreateTokenAccountInstruction(c.mint, c.signer.PublicKey())
+	if err != nil {
+		return "", err
+	}
+	ataPubKey, err := solana.PublicKeyFromBase58(ata)
+	if err != nil {
+		return "", fmt.Errorf("invalid associated token account address: %w", err)
+	}
+
+	// the bundled IDL failing to build is drift in our own copy of the layout
+	buy, err := buyInstructionFrom(c.mint, c.bondingCurve, c.associatedBondingCurve, ataPubKey, c.signer.PublicKey(), quote.TokenAmount, curve.MaxSolCost(quote.SolAmount, canarySlippageBps))
+	if err != nil {
+		return fmt.Sprintf("failed to build buy instruction: %v", err), nil
+	}
+	sell, err := sellInstructionFrom(c.mint, c.bondingCurve, c.associatedBondingCurve, ataPubKey, c.signer.PublicKey(), quote.TokenAmount, 0)
+	if err != nil {
+		return fmt.Sprintf("failed to build sell instruction: %v", err), nil
+	}
+
+	tx, err := c.client.signedTransaction(append(buyInstructionsFrom(ataCreateInstruction, buy), sell), simulationComputeUnitLimit, 0, solana.Hash{}, c.signer)
+	if err != nil {
+		return "", err
+	}
+
+	simulation, err := c.client.SimulateTransaction(tx)
+	return simulationDrift(simulation, err, c.mint, c.signer.PublicKey())
+}
+
+// simulationDrift tells a program that no longer accepts our buys and sells apart from a curve
+// that moved or a wallet that cannot pay. A transaction that fails before any instruction runs,
+// such as from an unfunded wallet, counts as drift too, since real trades would fail the same way.
+func simulationDrift(simulation *SimulationResult, err error, mint solana.PublicKey, user solana.PublicKey) (string, error) {
+	if err != nil {
+		var programErr *ProgramError
+		switch {
+		case errors.Is(err, ErrSlippageExceeded), errors.Is(err, ErrCurveComplete):
+			return "", fmt.Errorf("canary trade inconclusive: %w", err)
+		case errors.As(err, &programErr) && !isPumpProgramError(programErr):
+			return "", fmt.Errorf("canary trade inconclusive: %w", err)
+		case errors.Is(err, ErrTransactionFailed):
+			return fmt.Sprintf("simulated buy and sell failed: %v", err), nil
+		default:
+			return "", err
+		}
+	}
+
+	events, err := PumpEventsFromLogs(simulation.Logs)
+	if err != nil {
+		return fmt.Sprintf("trade events no longer decode: %v", err), nil
+	}
+
+	buys, sells := 0, 0
+	for _, trade := range events.Trades {
+		if !trade.Mint.Equals(mint) || !trade.User.Equals(user) {
+			continue
+		}
+		if trade.IsBuy {
+			buys++
+		} else {
+			sells++
+		}
+	}
+	if buys != 1 || sells != 1 {
+		return fmt.Sprintf("expected a buy and a sell trade event, got %d buys and %d sells", buys, sells), nil
+	}
+
+	return "", nil
+}
+
+// isPumpProgramError reads an unresolved program the way decodeTransactionError does, which also
+// keeps the system program, whose ID is all zeroes, from passing as pump's
+func isPumpProgramError(programErr *ProgramError) bool {
+	return programErr.ProgramID.Equals(PUMP_PROGRAM) || (programErr.ProgramID.IsZero() && programErr.Code >= anchorCustomErrorOffset)
+}
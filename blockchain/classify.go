@@ -0,0 +1,228 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/programs/token"
+)
+
+var (
+	buyInstructionDiscriminator      = pumpInstructionDiscriminator("buy")
+	sellInstructionDiscriminator     = pumpInstructionDiscriminator("sell")
+	createInstructionDiscriminator   = pumpInstructionDiscriminator("create")
+	withdrawInstructionDiscriminator = pumpInstructionDiscriminator("withdraw")
+	migrateInstructionDiscriminator  = pumpInstructionDiscriminator("migrate")
+)
+
+type TransactionKind int
+
+const (
+	OtherTransaction TransactionKind = iota
+	PumpBuyTransaction
+	PumpSellTransaction
+	CreateTransaction
+	TransferTransaction
+	MigrationTransaction
+)
+
+func (k TransactionKind) String() string {
+	switch k {
+	case PumpBuyTransaction:
+		return "pump buy"
+	case PumpSellTransaction:
+		return "pump sell"
+	case CreateTransaction:
+		return "create"
+	case TransferTransaction:
+		return "transfer"
+	case MigrationTransaction:
+		return "migration"
+	default:
+		return "other"
+	}
+}
+
+// Classification is what a wallet did in a transaction. Amounts are the wallet's own, raw token
+// units and lamports; a failed trade carries the amounts it asked for.
+type Classification struct {
+	Kind        TransactionKind
+	Signature   solana.Signature
+	Wallet      solana.PublicKey
+	Mint        solana.PublicKey // zero for SOL transfers and other transactions
+	SolAmount   uint64
+	TokenAmount uint64
+	Succeeded   bool
+}
+
+// ClassifyTransaction works out what the wallet did from the transaction's decoded pump.fun
+// instructions and events, falling back to its transfers
+func ClassifyTransaction(tx *ParsedTransaction, wallet solana.PublicKey) Classification {
+	classification := Classification{Signature: tx.Signature, Wallet: wallet, Succeeded: !tx.Failed()}
+
+	// a failed transaction logs no events, leaving only its instructions to go on
+	events, err := PumpEventsFromLogs(tx.LogMessages)
+	if err != nil {
+		events = &PumpEvents{}
+	}
+
+	pumpInstruction, pumpKind := mainPumpInstruction(tx.InstructionsFor(PUMP_PROGRAM), wallet, tx.FeePayer)
+	if pumpInstruction != nil {
+		classification.Kind = pumpKind
+
+		if mint, ok := pumpAccount(*pumpInstruction, "mint"); ok {
+			classification.Mint = mint
+		}
+
+		switch pumpKind {
+		case CreateTransaction:
+			if len(events.Creates) > 0 {
+				classification.Mint = events.Creates[0].Mint
+			}
+			// any dev buy the creator bundled in
+			if trade, ok := events.TradeBy(wallet.String()); ok && trade.Mint.Equals(classification.Mint) {
+				classification.SolAmount, classification.TokenAmount = trade.SolAmount, trade.TokenAmount
+			}
+		case PumpBuyTransaction, PumpSellTransaction:
+			if trade, ok := events.TradeBy(pumpTrader(*pumpInstruction).String()); ok {
+				classification.Mint, classification.SolAmount, classification.TokenAmount = trade.Mint, trade.SolAmount, trade.TokenAmount
+			} else if len(pumpInstruction.Data) >= 24 {
+				// the requested amount and the SOL limit, max cost for a buy or min output for a sell
+				classification.TokenAmount = binary.LittleEndian.Uint64(pumpInstruction.Data[8:16])
+				classification.SolAmount = binary.LittleEndian.Uint64(pumpInstruction.Data[16:24])
+			}
+		}
+
+		return classification
+	}
+
+	if transferred, ok := transfersFrom(tx, wallet); ok {
+		classification.Kind = TransferTransaction
+		classification.SolAmount = transferred.SolAmount
+		classification.TokenAmount = transferred.TokenAmount
+		classification.Mint = transferred.Mint
+	}
+
+	return classification
+}
+
+// mainPumpInstruction picks the pump.fun instruction that says what the wallet did. A create
+// outranks the dev buy bundled with it, and a trade by the wallet outranks one it only paid for.
+// Trades by others in a transaction that merely lists the wallet are not the wallet's.
+func mainPumpInstruction(instructions []ParsedInstruction, wallet solana.PublicKey, feePayer solana.PublicKey) (*ParsedInstruction, TransactionKind) {
+	rank := func(instruction ParsedInstruction, kind TransactionKind) int {
+		switch {
+		case kind == OtherTransaction:
+			return 0
+		case kind == CreateTransaction:
+			return 3
+		case kind == MigrationTransaction:
+			return 1
+		case pumpTrader(instruction).Equals(wallet):
+			return 2
+		case feePayer.Equals(wallet):
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	var chosen *ParsedInstruction
+	chosenKind, best := OtherTransaction, 0
+	for i := range instructions {
+		kind := pumpInstructionKind(instructions[i].Data)
+		if r := rank(instructions[i], kind); r > best {
+			chosen, chosenKind, best = &instructions[i], kind, r
+		}
+	}
+
+	return chosen, chosenKind
+}
+
+func pumpInstructionKind(data []byte) TransactionKind {
+	if len(data) < 8 {
+		return OtherTransaction
+	}
+
+	switch discriminator := data[:8]; {
+	case bytes.Equal(discriminator, buyInstructionDiscriminator):
+		return PumpBuyTransaction
+	case bytes.Equal(discriminator, sellInstructionDiscriminator):
+		return PumpSellTransaction
+	case bytes.Equal(discriminator, createInstructionDiscriminator):
+		return CreateTransaction
+	case bytes.Equal(discriminator, withdrawInstructionDiscriminator), bytes.Equal(discriminator, migrateInstructionDiscriminator):
+		return MigrationTransaction
+	default:
+		return OtherTransaction
+	}
+}
+
+// pumpTrader is the user of a buy or sell
+func pumpTrader(instruction ParsedInstruction) solana.PublicKey {
+	trader, _ := pumpAccount(instruction, "user")
+	return trader
+}
+
+// transfersFrom totals the SOL and tokens the wallet sent or received through the system and
+// token programs' transfer instructions
+func transfersFrom(tx *ParsedTransaction, wallet solana.PublicKey) (Classification, bool) {
+	transferred := Classification{}
+	found := false
+
+	for _, instruction := range tx.InstructionsFor(SYSTEM_PROGRAM) {
+		if len(instruction.Data) < 12 || binary.LittleEndian.Uint32(instruction.Data) != system.Instruction_Transfer || len(instruction.Accounts) < 2 {
+			continue
+		}
+		if instruction.Accounts[0].Equals(wallet) || instruction.Accounts[1].Equals(wallet) {
+			transferred.SolAmount += binary.LittleEndian.Uint64(instruction.Data[4:12])
+			found = true
+		}
+	}
+
+	tokenTransfers := false
+	for _, instruction := range tx.InstructionsFor(SYSTEM_TOKEN_PROGRAM) {
+		if len(instruction.Data) < 9 {
+			continue
+		}
+
+		// the owner signing for the source comes last in transfer and transferChecked alike
+		var owner solana.PublicKey
+		switch instruction.Data[0] {
+		case token.Instruction_Transfer:
+			if len(instruction.Accounts) < 3 {
+				continue
+			}
+			owner = instruction.Accounts[2]
+		case token.Instruction_TransferChecked:
+			if len(instruction.Accounts) < 4 {
+				continue
+			}
+			owner = instruction.Accounts[3]
+		default:
+			continue
+		}
+
+		tokenTransfers = true
+		if owner.Equals(wallet) {
+			transferred.TokenAmount += binary.LittleEndian.Uint64(instruction.Data[1:9])
+		}
+	}
+
+	// instructions name token accounts rather than their owners, so the mint and anything the
+	// wallet received come from its balance changes
+	if tokenTransfers {
+		for mint, delta := range tx.TokenDeltas[wallet] {
+			transferred.Mint = mint
+			if delta > 0 {
+				transferred.TokenAmount += uint64(delta)
+			}
+			found = true
+		}
+		found = found || transferred.TokenAmount > 0
+	}
+
+	return transferred, found
+}
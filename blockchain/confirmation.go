@@ -0,0 +1,187 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gorilla/websocket"
+)
+
+var ErrTransactionExpired = errors.New("blockhash expired, transaction not landed")
+
+type confirmationOptions struct {
+	pollInterval           time.Duration // used when the signature subscription could not be opened
+	subscribedPollInterval time.Duration // safety net alongside a live subscription
+	timeout                time.Duration
+}
+
+func defaultConfirmationOptions() confirmationOptions {
+	return confirmationOptions{
+		pollInterval:           500 * time.Millisecond,
+		subscribedPollInterval: 2 * time.Second,
+		timeout:                90 * time.Second,
+	}
+}
+
+type signatureNotification struct {
+	slot uint64
+	err  interface{}
+}
+
+// ConfirmTransaction waits until the transaction reaches the commitment target, listening on a
+// signatureSubscribe websocket and falling back to polling getSignatureStatuses. Once the block
+// height passes lastValidBlockHeight without the transaction landing, ErrTransactionExpired is returned.
+func (b *BlockchainClient) ConfirmTransaction(sig solana.Signature, lastValidBlockHeight uint64, commitment rpc.CommitmentType) (*Confirmation, error) {
+	start := time.Now()
+	opts := b.confirmationOptions
+
+	confirmed := func(slot uint64) *Confirmation {
+		return &Confirmation{Signature: sig.String(), Slot: slot, Commitment: commitment, Elapsed: time.Since(start)}
+	}
+
+	notificationCh, closeSubscription, err := b.subscribeToSignature(sig, commitment)
+	pollInterval := opts.subscribedPollInterval
+	if err != nil {
+		log.Printf("Signature subscription failed, polling instead: %v", err)
+		pollInterval = opts.pollInterval
+	} else {
+		defer closeSubscription()
+	}
+
+	pollTicker := time.NewTicker(pollInterval)
+	defer pollTicker.Stop()
+	timeout := time.After(opts.timeout)
+
+	for {
+		// the subscription only fires for future commitments, so check straight away too
+		status, err := b.signatureStatus(sig)
+		if err != nil {
+			log.Printf("Failed to get signature status: %v", err)
+		} else if status != nil {
+			if status.Err != nil {
+				return nil, decodeTransactionError(nil, status.Err)
+			}
+			if commitmentReached(status.ConfirmationStatus, commitment) {
+				return confirmed(status.Slot), nil
+			}
+		} else if b.blockhashExpired(lastValidBlockHeight) {
+			return nil, ErrTransactionExpired
+		}
+
+		select {
+		case notification, ok := <-notificationCh:
+			if !ok {
+				notificationCh = nil
+				pollTicker.Reset(opts.pollInterval)
+				continue
+			}
+			if notification.err != nil {
+				return nil, decodeTransactionError(nil, notification.err)
+			}
+			return confirmed(notification.slot), nil
+		case <-pollTicker.C:
+		case <-timeout:
+			return nil, fmt.Errorf("transaction confirmation timed out after %v", opts.timeout)
+		}
+	}
+}
+
+func (b *BlockchainClient) signatureStatus(sig solana.Signature) (*rpc.SignatureStatusesResult, error) {
+	statuses, err := b.client.GetSignatureStatuses(context.Background(), true, sig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction status: %w", err)
+	}
+	if len(statuses.Value) == 0 {
+		return nil, nil
+	}
+	return statuses.Value[0], nil
+}
+
+func (b *BlockchainClient) blockhashExpired(lastValidBlockHeight uint64) bool {
+	if lastValidBlockHeight == 0 {
+		return false
+	}
+
+	// a running refresher already tracks the height, so no need to ask the node
+	if blockHeight, fresh := b.blockhashCache.BlockHeight(); fresh {
+		return blockHeight > lastValidBlockHeight
+	}
+
+	blockHeight, err := b.client.GetBlockHeight(context.Background(), rpc.CommitmentConfirmed)
+	if err != nil {
+		log.Printf("Failed to get block height: %v", err)
+		return false
+	}
+	return blockHeight > lastValidBlockHeight
+}
+
+// subscribeToSignature opens a signatureSubscribe websocket; the channel receives at most one
+// notification and is closed if the socket drops
+func (b *BlockchainClient) subscribeToSignature(sig solana.Signature, commitment rpc.CommitmentType) (<-chan signatureNotification, func(), error) {
+	conn, _, err := websocket.DefaultDialer.Dial(b.wsUrl, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to WebSocket: %v", err)
+	}
+
+	subscriptionMessage := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "signatureSubscribe",
+		"params": []interface{}{
+			sig.String(),
+			map[string]interface{}{
+				"commitment": commitment,
+			},
+		},
+	}
+
+	if err := conn.WriteJSON(subscriptionMessage); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to send signature subscription: %v", err)
+	}
+
+	notificationCh := make(chan signatureNotification, 1)
+
+	go func() {
+		defer close(notificationCh)
+		for {
+			var message struct {
+				Method string `json:"method"`
+				Params struct {
+					Result struct {
+						Context struct {
+							Slot uint64 `json:"slot"`
+						} `json:"context"`
+						Value struct {
+							Err interface{} `json:"err"`
+						} `json:"value"`
+					} `json:"result"`
+				} `json:"params"`
+			}
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+
+			if message.Method == "signatureNotification" {
+				notificationCh <- signatureNotification{slot: message.Params.Result.Context.Slot, err: message.Params.Result.Value.Err}
+				return
+			}
+		}
+	}()
+
+	return notificationCh, func() { conn.Close() }, nil
+}
+
+func commitmentReached(status rpc.ConfirmationStatusType, target rpc.CommitmentType) bool {
+	rank := map[string]int{
+		string(rpc.CommitmentProcessed): 1,
+		string(rpc.CommitmentConfirmed): 2,
+		string(rpc.CommitmentFinalized): 3,
+	}
+	return rank[string(status)] >= rank[string(target)] && rank[string(status)] > 0
+}
@@ -0,0 +1,296 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+const (
+	maxEndpointSlotLag        = 50 // slots behind the best endpoint before reads move elsewhere
+	endpointFailureCooldown   = 10 * time.Second
+	endpointSlotCheckInterval = 2 * time.Second
+)
+
+type EndpointStats struct {
+	Url        string
+	Slot       uint64
+	Reads      uint64
+	ReadErrors uint64
+	Sends      uint64
+	SendErrors uint64
+	LastError  string
+
+	readLatency time.Duration // totals, averaged by the accessors
+	sendLatency time.Duration
+}
+
+func (s EndpointStats) AverageReadLatency() time.Duration {
+	if s.Reads == 0 {
+		return 0
+	}
+	return s.readLatency / time.Duration(s.Reads)
+}
+
+func (s EndpointStats) AverageSendLatency() time.Duration {
+	if s.Sends == 0 {
+		return 0
+	}
+	return s.sendLatency / time.Duration(s.Sends)
+}
+
+type endpoint struct {
+	client *rpc.Client
+
+	mu       sync.Mutex
+	stats    EndpointStats
+	failedAt time.Time
+}
+
+func newEndpoint(url string) *endpoint {
+	return &endpoint{client: rpc.New(url), stats: EndpointStats{Url: url}}
+}
+
+func (e *endpoint) recordRead(latency time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.stats.Reads++
+	e.stats.readLatency += latency
+	if err != nil {
+		e.stats.ReadErrors++
+		e.stats.LastError = err.Error()
+		e.failedAt = time.Now()
+	}
+}
+
+func (e *endpoint) recordSend(latency time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.stats.Sends++
+	e.stats.sendLatency += latency
+	if err != nil {
+		e.stats.SendErrors++
+		e.stats.LastError = err.Error()
+	}
+}
+
+func (e *endpoint) snapshot() EndpointStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.stats
+}
+
+// endpointPool is a JSON-RPC client that sends each read to the first healthy endpoint, in
+// priority order, and fails over to the next when one errors. An endpoint is unhealthy for a
+// cooldown after a failure or while its slot lags the best endpoint.
+type endpointPool struct {
+	endpoints         []*endpoint // primary first
+	maxSlotLag        uint64
+	failureCooldown   time.Duration
+	slotCheckInterval time.Duration
+
+	mu            sync.Mutex
+	lastSlotCheck time.Time
+}
+
+func newEndpointPool(endpoints []*endpoint) *endpointPool {
+	return &endpointPool{
+		endpoints:         endpoints,
+		maxSlotLag:        maxEndpointSlotLag,
+		failureCooldown:   endpointFailureCooldown,
+		slotCheckInterval: endpointSlotCheckInterval,
+	}
+}
+
+func (p *endpointPool) CallForInto(ctx context.Context, out interface{}, method string, params []interface{}) error {
+	return p.call(func(e *endpoint) error {
+		return e.client.RPCCallForInto(ctx, out, method, params)
+	})
+}
+
+func (p *endpointPool) CallWithCallback(ctx context.Context, method string, params []interface{}, callback func(*http.Request, *http.Response) error) error {
+	return p.call(func(e *endpoint) error {
+		return e.client.RPCCallWithCallback(ctx, method, params, callback)
+	})
+}
+
+func (p *endpointPool) CallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error) {
+	var responses jsonrpc.RPCResponses
+	err := p.call(func(e *endpoint) error {
+		var err error
+		responses, err = e.client.RPCCallBatch(ctx, requests)
+		return err
+	})
+	return responses, err
+}
+
+func (p *endpointPool) call(do func(e *endpoint) error) error {
+	p.checkSlotsIfDue()
+
+	var lastErr error
+	for _, e := range p.ordered() {
+		start := time.Now()
+		err := do(e)
+
+		// an error response from the node is an answer, not an endpoint failure
+		var rpcErr *jsonrpc.RPCError
+		if err == nil || errors.As(err, &rpcErr) {
+			e.recordRead(time.Since(start), nil)
+			return err
+		}
+
+		e.recordRead(time.Since(start), err)
+		lastErr = err
+	}
+
+	return fmt.Errorf("all %d endpoints failed: %w", len(p.endpoints), lastErr)
+}
+
+// ordered returns the healthy endpoints in priority order followed by the unhealthy ones, which
+// are still tried as a last resort
+func (p *endpointPool) ordered() []*endpoint {
+	var bestSlot uint64
+	for _, e := range p.endpoints {
+		bestSlot = max(bestSlot, e.snapshot().Slot)
+	}
+
+	healthy := make([]*endpoint, 0, len(p.endpoints))
+	unhealthy := []*endpoint{}
+	for _, e := range p.endpoints {
+		e.mu.Lock()
+		failing := time.Since(e.failedAt) < p.failureCooldown
+		lagging := e.stats.Slot+p.maxSlotLag < bestSlot
+		e.mu.Unlock()
+
+		if failing || lagging {
+			unhealthy = append(unhealthy, e)
+		} else {
+			healthy = append(healthy, e)
+		}
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+func (p *endpointPool) checkSlotsIfDue() {
+	p.mu.Lock()
+	if time.Since(p.lastSlotCheck) < p.slotCheckInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastSlotCheck = time.Now()
+	p.mu.Unlock()
+
+	go p.checkSlots()
+}
+
+func (p *endpointPool) checkSlots() {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), p.slotCheckInterval)
+			defer cancel()
+
+			slot, err := e.client.GetSlot(ctx, rpc.CommitmentProcessed)
+			if err != nil {
+				return
+			}
+
+			e.mu.Lock()
+			e.stats.Slot = slot
+			e.mu.Unlock()
+		}(e)
+	}
+	wg.Wait()
+}
+
+// BroadcastSender sends each transaction to every endpoint at once. The signature is the same
+// everywhere, so whichever copy lands first confirms it.
+type BroadcastSender struct {
+	endpoints []*endpoint
+}
+
+func (s *BroadcastSender) Instructions(payer solana.PublicKey) []solana.Instruction {
+	return nil
+}
+
+// Send returns as soon as one endpoint accepts the transaction; the rest finish in the background
+// so their latency and errors are still recorded
+func (s *BroadcastSender) Send(tx *solana.Transaction, skipPreflight bool) (solana.Signature, error) {
+	type sendResult struct {
+		sig solana.Signature
+		err error
+	}
+	resultCh := make(chan sendResult, len(s.endpoints))
+
+	for _, e := range s.endpoints {
+		go func(e *endpoint) {
+			start := time.Now()
+			sig, err := e.client.SendTransactionWithOpts(context.Background(), tx, rpc.TransactionOpts{
+				SkipPreflight:       skipPreflight,
+				PreflightCommitment: rpc.CommitmentProcessed,
+			})
+			e.recordSend(time.Since(start), err)
+			resultCh <- sendResult{sig, err}
+		}(e)
+	}
+
+	var errs []error
+	for range s.endpoints {
+		result := <-resultCh
+		if result.err == nil {
+			return result.sig, nil
+		}
+		errs = append(errs, result.err)
+	}
+
+	return solana.Signature{}, fmt.Errorf("all %d endpoints rejected the transaction: %w", len(s.endpoints), errors.Join(errs...))
+}
+
+// SetEndpoints adds fallback RPC endpoints behind the primary Helius one and extra send-only
+// endpoints. Reads fail over across the RPC endpoints and, unless SetTxSender chose another sender,
+// transactions are broadcast to all of them.
+func (b *BlockchainClient) SetEndpoints(rpcUrls []string, sendUrls []string) {
+	readEndpoints := []*endpoint{newEndpoint(fmt.Sprintf("%s%s", restEndpoint, b.apiKey))}
+	for _, url := range rpcUrls {
+		readEndpoints = append(readEndpoints, newEndpoint(url))
+	}
+
+	sendEndpoints := append([]*endpoint{}, readEndpoints...)
+	for _, url := range sendUrls {
+		sendEndpoints = append(sendEndpoints, newEndpoint(url))
+	}
+
+	b.setEndpoints(readEndpoints, sendEndpoints)
+}
+
+func (b *BlockchainClient) setEndpoints(readEndpoints []*endpoint, sendEndpoints []*endpoint) {
+	b.client = rpc.NewWithCustomRPCClient(newEndpointPool(readEndpoints))
+	b.feeEstimator.client = b.client
+	b.blockhashCache.client = b.client
+	if !b.txSenderPinned {
+		b.txSender = &BroadcastSender{sendEndpoints}
+	}
+	b.endpoints = sendEndpoints
+}
+
+// EndpointStats reports per-endpoint latency and errors for reads and sends
+func (b *BlockchainClient) EndpointStats() []EndpointStats {
+	stats := make([]EndpointStats, len(b.endpoints))
+	for i, e := range b.endpoints {
+		stats[i] = e.snapshot()
+	}
+	return stats
+}
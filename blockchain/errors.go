@@ -0,0 +1,114 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	anchorCustomErrorOffset = 6000
+	tokenInsufficientFunds  = 1
+)
+
+var (
+	ErrTransactionFailed = errors.New("transaction failed")
+	ErrSlippageExceeded  = errors.New("slippage exceeded")
+	ErrCurveComplete     = errors.New("bonding curve complete")
+	ErrNotEnoughTokens   = curve.ErrNotEnoughTokens
+	ErrMintMismatch      = errors.New("mint does not match bonding curve")
+	ErrProgramDrift      = errors.New("pump program drifted from what trades are built against")
+)
+
+// pumpErrors maps the pump program's anchor error codes to their IDL names and, where callers
+// need to branch on them, a sentinel error
+var pumpErrors = map[uint32]struct {
+	name string
+	err  error
+}{
+	6000: {"NotAuthorized", ErrTransactionFailed},
+	6001: {"AlreadyInitialized", ErrTransactionFailed},
+	6002: {"TooMuchSolRequired", ErrSlippageExceeded},
+	6003: {"TooLittleSolReceived", ErrSlippageExceeded},
+	6004: {"MintDoesNotMatchBondingCurve", ErrMintMismatch},
+	6005: {"BondingCurveComplete", ErrCurveComplete},
+	6006: {"BondingCurveNotComplete", ErrTransactionFailed},
+	6007: {"NotInitialized", ErrTransactionFailed},
+	6008: {"WithdrawTooFrequent", ErrTransactionFailed},
+	6020: {"BuyZeroAmount", ErrTransactionFailed},
+	6021: {"NotEnoughTokensToBuy", ErrNotEnoughTokens},
+	6022: {"SellZeroAmount", ErrTransactionFailed},
+	6023: {"NotEnoughTokensToSell", ErrNotEnoughTokens},
+	6024: {"Overflow", ErrTransactionFailed},
+}
+
+// ProgramError is a failed instruction decoded from a transaction error. It unwraps to one of
+// the sentinel errors above so callers can branch with errors.Is.
+type ProgramError struct {
+	InstructionIndex int
+	ProgramID        solana.PublicKey // zero if the failing program could not be resolved
+	Code             uint32
+	Name             string
+	err              error
+}
+
+func (e *ProgramError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("%v: instruction %d failed with %s (custom program error %d)", e.err, e.InstructionIndex, e.Name, e.Code)
+	}
+	return fmt.Sprintf("%v: instruction %d failed with custom program error %d", e.err, e.InstructionIndex, e.Code)
+}
+
+func (e *ProgramError) Unwrap() error {
+	return e.err
+}
+
+// decodeTransactionError turns an rpc transaction error into a Go error. tx is used to resolve
+// which program failed and may be nil, in which case anchor range codes are assumed to be pump's.
+func decodeTransactionError(tx *solana.Transaction, txErr interface{}) error {
+	raw, err := json.Marshal(txErr)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTransactionFailed, txErr)
+	}
+
+	var parsed struct {
+		InstructionError []json.RawMessage `json:"InstructionError"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil || len(parsed.InstructionError) != 2 {
+		return fmt.Errorf("%w: %s", ErrTransactionFailed, raw)
+	}
+
+	var instructionIndex int
+	var custom struct {
+		Custom *uint32 `json:"Custom"`
+	}
+	if json.Unmarshal(parsed.InstructionError[0], &instructionIndex) != nil ||
+		json.Unmarshal(parsed.InstructionError[1], &custom) != nil ||
+		custom.Custom == nil {
+		return fmt.Errorf("%w: %s", ErrTransactionFailed, raw)
+	}
+
+	programErr := &ProgramError{InstructionIndex: instructionIndex, Code: *custom.Custom, err: ErrTransactionFailed}
+	if tx != nil && instructionIndex < len(tx.Message.Instructions) {
+		programID, err := tx.Message.Program(tx.Message.Instructions[instructionIndex].ProgramIDIndex)
+		if err == nil {
+			programErr.ProgramID = programID
+		}
+	}
+
+	switch {
+	case programErr.ProgramID.Equals(SYSTEM_TOKEN_PROGRAM) && programErr.Code == tokenInsufficientFunds:
+		programErr.Name = "InsufficientFunds"
+		programErr.err = ErrNotEnoughTokens
+	case programErr.ProgramID.Equals(PUMP_PROGRAM) || (programErr.ProgramID.IsZero() && programErr.Code >= anchorCustomErrorOffset):
+		if pumpErr, ok := pumpErrors[programErr.Code]; ok {
+			programErr.Name = pumpErr.name
+			programErr.err = pumpErr.err
+		}
+	}
+
+	return programErr
+}
@@ -0,0 +1,112 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+)
+
+const programDataPrefix = "Program data: "
+
+var (
+	tradeEventDiscriminator    = eventDiscriminator("TradeEvent")
+	createEventDiscriminator   = eventDiscriminator("CreateEvent")
+	completeEventDiscriminator = eventDiscriminator("CompleteEvent")
+)
+
+// TradeEvent is emitted by the pump program on every buy and sell
+type TradeEvent struct {
+	Mint                 solana.PublicKey
+	SolAmount            uint64
+	TokenAmount          uint64
+	IsBuy                bool
+	User                 solana.PublicKey
+	Timestamp            int64
+	VirtualSolReserves   uint64
+	VirtualTokenReserves uint64
+}
+
+// CreateEvent is emitted by the pump program when a new token is launched
+type CreateEvent struct {
+	Name         string
+	Symbol       string
+	Uri          string
+	Mint         solana.PublicKey
+	BondingCurve solana.PublicKey
+	User         solana.PublicKey
+}
+
+// CompleteEvent is emitted by the pump program when a bonding curve fills up
+type CompleteEvent struct {
+	User         solana.PublicKey
+	Mint         solana.PublicKey
+	BondingCurve solana.PublicKey
+	Timestamp    int64
+}
+
+type PumpEvents struct {
+	Trades    []TradeEvent
+	Creates   []CreateEvent
+	Completes []CompleteEvent
+}
+
+// PumpEventsFromLogs decodes every pump.fun event found in the "Program data:" lines of a
+// transaction's logs. Lines belonging to other programs are skipped.
+func PumpEventsFromLogs(logs []string) (*PumpEvents, error) {
+	events := &PumpEvents{}
+
+	for _, log := range logs {
+		if !strings.HasPrefix(log, programDataPrefix) {
+			continue
+		}
+
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(log, programDataPrefix))
+		if err != nil || len(data) < 8 {
+			continue
+		}
+
+		discriminator, payload := data[:8], data[8:]
+		switch {
+		case bytes.Equal(discriminator, tradeEventDiscriminator):
+			var event TradeEvent
+			if err := bin.NewBorshDecoder(payload).Decode(&event); err != nil {
+				return nil, fmt.Errorf("failed to decode trade event: %w", err)
+			}
+			events.Trades = append(events.Trades, event)
+		case bytes.Equal(discriminator, createEventDiscriminator):
+			var event CreateEvent
+			if err := bin.NewBorshDecoder(payload).Decode(&event); err != nil {
+				return nil, fmt.Errorf("failed to decode create event: %w", err)
+			}
+			events.Creates = append(events.Creates, event)
+		case bytes.Equal(discriminator, completeEventDiscriminator):
+			var event CompleteEvent
+			if err := bin.NewBorshDecoder(payload).Decode(&event); err != nil {
+				return nil, fmt.Errorf("failed to decode complete event: %w", err)
+			}
+			events.Completes = append(events.Completes, event)
+		}
+	}
+
+	return events, nil
+}
+
+// TradeBy returns the first trade in the events made by user
+func (e *PumpEvents) TradeBy(user string) (*TradeEvent, bool) {
+	for i := range e.Trades {
+		if e.Trades[i].User.String() == user {
+			return &e.Trades[i], true
+		}
+	}
+	return nil, false
+}
+
+func eventDiscriminator(eventName string) []byte {
+	hash := sha256.Sum256([]byte("event:" + eventName))
+	return hash[:8]
+}
@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// FeeEstimator prices compute units from recent prioritization fees paid on the accounts a
+// transaction writes to
+type FeeEstimator struct {
+	client               *rpc.Client
+	minPriorityFee       uint64  // micro-lamports per compute unit
+	maxPriorityFee       uint64  // micro-lamports per compute unit
+	escalationMultiplier float64 // applied once per retry
+}
+
+func NewFeeEstimator(client *rpc.Client, minPriorityFee uint64, maxPriorityFee uint64, escalationMultiplier float64) *FeeEstimator {
+	return &FeeEstimator{client, minPriorityFee, maxPriorityFee, escalationMultiplier}
+}
+
+// Estimate returns the percentile (0-100) of recent prioritization fees for the accounts,
+// clamped to the estimator's bounds
+func (f *FeeEstimator) Estimate(percentile float64, accounts ...solana.PublicKey) (uint64, error) {
+	result, err := f.client.GetRecentPrioritizationFees(context.Background(), accounts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get recent prioritization fees: %w", err)
+	}
+
+	fees := make([]uint64, len(result))
+	for i, r := range result {
+		fees[i] = r.PrioritizationFee
+	}
+
+	return f.clamp(feePercentile(fees, percentile)), nil
+}
+
+// EstimateForAttempt estimates the fee and escalates it for the given retry attempt, falling
+// back to the minimum fee if the estimate is unavailable
+func (f *FeeEstimator) EstimateForAttempt(percentile float64, attempt int, accounts ...solana.PublicKey) uint64 {
+	fee, err := f.Estimate(percentile, accounts...)
+	if err != nil {
+		log.Printf("Failed to estimate priority fee, using minimum: %v", err)
+		fee = f.minPriorityFee
+	}
+	return f.Escalate(fee, attempt)
+}
+
+// Escalate raises fee by the escalation multiplier once per attempt, up to the maximum fee
+func (f *FeeEstimator) Escalate(fee uint64, attempt int) uint64 {
+	escalated := float64(max(fee, f.minPriorityFee)) * math.Pow(f.escalationMultiplier, float64(attempt))
+	if escalated >= float64(f.maxPriorityFee) {
+		return f.maxPriorityFee
+	}
+	return f.clamp(uint64(escalated))
+}
+
+func (f *FeeEstimator) clamp(fee uint64) uint64 {
+	return min(max(fee, f.minPriorityFee), f.maxPriorityFee)
+}
+
+func feePercentile(fees []uint64, percentile float64) uint64 {
+	if len(fees) == 0 {
+		return 0
+	}
+
+	sorted := append([]uint64{}, fees...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	index := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	return sorted[min(max(index, 0), len(sorted)-1)]
+}
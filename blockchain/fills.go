@@ -0,0 +1,106 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	fillFetchRetries = 5
+	basisPoints      = 10_000
+)
+
+// Fill is what a trade actually did, read back from the landed transaction rather than quoted
+type Fill struct {
+	Slot        uint64
+	TokenAmount uint64 // raw tokens received on a buy or sold on a sell
+	SolAmount   uint64 // lamports spent on a buy or received on a sell, pump.fun's fee included
+	PumpFee     uint64 // lamports
+	NetworkFee  uint64 // base and priority fee in lamports
+	SlippageBps int64  // how much worse than the quote the trade filled, negative if better
+}
+
+func (f *Fill) UiTokenAmount() float64 {
+	return uiTokenAmount(f.TokenAmount)
+}
+
+func (f *Fill) Sol() float64 {
+	return float64(f.SolAmount) / lamportsPerSol
+}
+
+// fillFor fetches our landed trade and reconciles it against the quote it was sent with
+func (b *BlockchainClient) fillFor(signature solana.Signature, wallet solana.PublicKey, mint solana.PublicKey, bondingCurve solana.PublicKey, quote *curve.Quote, isBuy bool) (*Fill, error) {
+	tx, err := b.GetTransactionDataWithRetries(signature.String(), fillFetchRetries)
+	if err != nil {
+		return nil, err
+	}
+
+	return fillFrom(tx, wallet, mint, bondingCurve, quote, isBuy)
+}
+
+// fillFrom reads the SOL side of a trade off the bonding curve and fee recipient, which only the
+// trade touches, so token account rent and tips paid in the same transaction stay out of it
+func fillFrom(tx *ParsedTransaction, wallet solana.PublicKey, mint solana.PublicKey, bondingCurve solana.PublicKey, quote *curve.Quote, isBuy bool) (*Fill, error) {
+	if tx.Failed() {
+		return nil, fmt.Errorf("transaction %s failed: %v", tx.Signature, tx.Err)
+	}
+
+	tokenDelta := tx.TokenDelta(wallet, mint)
+	curveDelta := tx.SolDelta(bondingCurve)
+	pumpFee := tx.SolDelta(PUMP_FEE)
+	if pumpFee < 0 {
+		return nil, fmt.Errorf("fee recipient lost %d lamports in %s", -pumpFee, tx.Signature)
+	}
+
+	fill := &Fill{Slot: tx.Slot, PumpFee: uint64(pumpFee), NetworkFee: tx.Fee}
+	if isBuy {
+		if tokenDelta <= 0 || curveDelta <= 0 {
+			return nil, fmt.Errorf("transaction %s is not a buy of %s by %s", tx.Signature, mint, wallet)
+		}
+		fill.TokenAmount = uint64(tokenDelta)
+		fill.SolAmount = uint64(curveDelta + pumpFee)
+	} else {
+		if tokenDelta >= 0 || curveDelta >= 0 || -curveDelta < pumpFee {
+			return nil, fmt.Errorf("transaction %s is not a sell of %s by %s", tx.Signature, mint, wallet)
+		}
+		fill.TokenAmount = uint64(-tokenDelta)
+		fill.SolAmount = uint64(-curveDelta - pumpFee)
+	}
+
+	fill.SlippageBps = slippageBpsFor(fill, quote, isBuy)
+	return fill, nil
+}
+
+// poolFillFrom reads a sell through a graduated coin's AMM pool. The SOL side comes off the pool's
+// wrapped SOL vault, since our own wrapped SOL account is opened and closed in the same transaction.
+func poolFillFrom(tx *ParsedTransaction, wallet solana.PublicKey, mint solana.PublicKey, poolAuthority solana.PublicKey, quote *curve.Quote) (*Fill, error) {
+	if tx.Failed() {
+		return nil, fmt.Errorf("transaction %s failed: %v", tx.Signature, tx.Err)
+	}
+
+	tokenDelta := tx.TokenDelta(wallet, mint)
+	poolSolDelta := tx.TokenDelta(poolAuthority, SOL)
+	if tokenDelta >= 0 || poolSolDelta >= 0 {
+		return nil, fmt.Errorf("transaction %s is not a pool sell of %s by %s", tx.Signature, mint, wallet)
+	}
+
+	fill := &Fill{Slot: tx.Slot, TokenAmount: uint64(-tokenDelta), SolAmount: uint64(-poolSolDelta), NetworkFee: tx.Fee}
+	fill.SlippageBps = slippageBpsFor(fill, quote, false)
+	return fill, nil
+}
+
+// slippageBpsFor is how much worse than the quote the fill was: buys pay more and sells receive
+// less when the price moved against us
+func slippageBpsFor(fill *Fill, quote *curve.Quote, isBuy bool) int64 {
+	if quote == nil || quote.SolAmount == 0 {
+		return 0
+	}
+
+	slippage := int64(fill.SolAmount) - int64(quote.SolAmount)
+	if !isBuy {
+		slippage = -slippage
+	}
+	return slippage * basisPoints / int64(quote.SolAmount)
+}
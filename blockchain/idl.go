@@ -0,0 +1,116 @@
+package blockchain
+
+import (
+	"bytes"
+	_ "embed"
+
+	"github.com/ethanhosier/pumpfun-trade-bot/idl"
+	"github.com/gagliardetto/solana-go"
+)
+
+// pumpIDLJSON is pump.fun's Anchor IDL. When the program is upgraded, updating this file updates
+// the discriminators, argument layouts and account order buys and sells are built with.
+//
+//go:embed idl/pump.json
+var pumpIDLJSON []byte
+
+var pumpIDL = idl.MustParse(pumpIDLJSON)
+
+// pumpInstructionDiscriminator reads the discriminator from the bundled IDL, which must describe
+// every instruction the bot decodes
+func pumpInstructionDiscriminator(name string) []byte {
+	instruction, err := pumpIDL.Instruction(name)
+	if err != nil {
+		panic(err)
+	}
+	return instruction.Discriminator()
+}
+
+// pumpAccount looks up an account of a decoded pump.fun instruction by its IDL name, so the
+// decoding follows the bundled layout rather than fixed positions
+func pumpAccount(instruction ParsedInstruction, name string) (solana.PublicKey, bool) {
+	if len(instruction.Data) < 8 {
+		return solana.PublicKey{}, false
+	}
+
+	for i := range pumpIDL.Instructions {
+		described := &pumpIDL.Instructions[i]
+		if !bytes.Equal(described.Discriminator(), instruction.Data[:8]) {
+			continue
+		}
+
+		index, ok := described.AccountIndex(name)
+		if !ok || index >= len(instruction.Accounts) {
+			return solana.PublicKey{}, false
+		}
+		return instruction.Accounts[index], true
+	}
+	return solana.PublicKey{}, false
+}
+
+// pumpFixedAccounts are the accounts every pump.fun instruction names the same way, by their IDL names
+func pumpFixedAccounts() map[string]solana.PublicKey {
+	return map[string]solana.PublicKey{
+		"global":                 PUMP_GLOBAL,
+		"feeRecipient":           PUMP_FEE,
+		"eventAuthority":         PUMP_EVENT_AUTHORITY,
+		"program":                PUMP_PROGRAM,
+		"systemProgram":          SYSTEM_PROGRAM,
+		"tokenProgram":           SYSTEM_TOKEN_PROGRAM,
+		"associatedTokenProgram": SYSTEM_ASSOCIATED_TOKEN_ACCOUNT_PROGRAM,
+		"rent":                   SYSTEM_RENT,
+	}
+}
+
+// pumpInstructionFrom builds a pump.fun instruction from the bundled IDL, given the accounts that
+// vary per trade
+func pumpInstructionFrom(name string, accounts map[string]solana.PublicKey, args map[string]interface{}) (*solana.GenericInstruction, error) {
+	named := pumpFixedAccounts()
+	for accountName, key := range accounts {
+		named[accountName] = key
+	}
+
+	return pumpIDL.Build(name, named, args)
+}
+
+func buyInstructionFrom(
+	mintPubKey solana.PublicKey,
+	bondingCurvePubKey solana.PublicKey,
+	associatedBondingCurvePubKey solana.PublicKey,
+	ataPubKey solana.PublicKey,
+	payerPubKey solana.PublicKey,
+	amount uint64,
+	maxSolCost uint64,
+) (*solana.GenericInstruction, error) {
+	return pumpInstructionFrom("buy", map[string]solana.PublicKey{
+		"mint":                   mintPubKey,
+		"bondingCurve":           bondingCurvePubKey,
+		"associatedBondingCurve": associatedBondingCurvePubKey,
+		"associatedUser":         ataPubKey,
+		"user":                   payerPubKey,
+	}, map[string]interface{}{
+		"amount":     amount,
+		"maxSolCost": maxSolCost,
+	})
+}
+
+func sellInstructionFrom(
+	mintPubKey solana.PublicKey,
+	bondingCurvePubKey solana.PublicKey,
+	associatedBondingCurvePubKey solana.PublicKey,
+	ataPubKey solana.PublicKey,
+	payerPubKey solana.PublicKey,
+	amount uint64,
+	minSolOutput uint64,
+) (*solana.GenericInstruction, error) {
+	return pumpInstructionFrom("sell", map[string]solana.PublicKey{
+		"mint":                   mintPubKey,
+		"bondingCurve":           bondingCurvePubKey,
+		"associatedBondingCurve": associatedBondingCurvePubKey,
+		"associatedUser":         ataPubKey,
+		"user":                   payerPubKey,
+	}, map[string]interface{}{
+		"amount":       amount,
+		"minSolOutput": minSolOutput,
+	})
+}
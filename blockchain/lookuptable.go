@@ -0,0 +1,146 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	createLookupTableInstruction = 0
+	extendLookupTableInstruction = 2
+
+	lookupTableAddressesPerExtend = 20 // keeps the extend transaction well inside the size limit
+)
+
+var ADDRESS_LOOKUP_TABLE_PROGRAM = solana.MustPublicKeyFromBase58("AddressLookupTab1e1111111111111111111111111")
+
+// PumpLookupTableAddresses are the accounts every pump.fun buy and sell references, worth a lookup
+// table entry instead of 32 bytes each in the transaction
+func PumpLookupTableAddresses() []solana.PublicKey {
+	return []solana.PublicKey{
+		PUMP_GLOBAL,
+		PUMP_FEE,
+		PUMP_EVENT_AUTHORITY,
+		SYSTEM_PROGRAM,
+		SYSTEM_TOKEN_PROGRAM,
+		SYSTEM_ASSOCIATED_TOKEN_ACCOUNT_PROGRAM, // sells
+		SYSTEM_RENT,                             // buys
+	}
+}
+
+// lookupTableAddressFor derives the table an authority creates at a recent slot
+func lookupTableAddressFor(authority solana.PublicKey, recentSlot uint64) (solana.PublicKey, uint8, error) {
+	return solana.FindProgramAddress(
+		[][]byte{authority.Bytes(), binary.LittleEndian.AppendUint64(nil, recentSlot)},
+		ADDRESS_LOOKUP_TABLE_PROGRAM,
+	)
+}
+
+func createLookupTableInstructionFrom(table solana.PublicKey, authority solana.PublicKey, recentSlot uint64, bump uint8) solana.Instruction {
+	data := binary.LittleEndian.AppendUint32(nil, createLookupTableInstruction)
+	data = binary.LittleEndian.AppendUint64(data, recentSlot)
+	data = append(data, bump)
+
+	return solana.NewInstruction(ADDRESS_LOOKUP_TABLE_PROGRAM, lookupTableAccountsFrom(table, authority), data)
+}
+
+func extendLookupTableInstructionFrom(table solana.PublicKey, authority solana.PublicKey, addresses []solana.PublicKey) solana.Instruction {
+	data := binary.LittleEndian.AppendUint32(nil, extendLookupTableInstruction)
+	data = binary.LittleEndian.AppendUint64(data, uint64(len(addresses)))
+	for _, address := range addresses {
+		data = append(data, address.Bytes()...)
+	}
+
+	return solana.NewInstruction(ADDRESS_LOOKUP_TABLE_PROGRAM, lookupTableAccountsFrom(table, authority), data)
+}
+
+func lookupTableAccountsFrom(table solana.PublicKey, authority solana.PublicKey) []*solana.AccountMeta {
+	return []*solana.AccountMeta{
+		solana.NewAccountMeta(table, true, false),           // Lookup Table
+		solana.NewAccountMeta(authority, false, true),       // Authority
+		solana.NewAccountMeta(authority, true, true),        // Payer
+		solana.NewAccountMeta(SYSTEM_PROGRAM, false, false), // SYSTEM_PROGRAM
+	}
+}
+
+// CreateLookupTable creates an address lookup table owned by the signer holding the addresses,
+// returning the table address once the creation is confirmed
+func (b *BlockchainClient) CreateLookupTable(signer Signer, addresses []solana.PublicKey) (solana.PublicKey, error) {
+	recentSlot, err := b.client.GetSlot(context.Background(), rpc.CommitmentFinalized)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to get recent slot: %w", err)
+	}
+
+	table, bump, err := lookupTableAddressFor(signer.PublicKey(), recentSlot)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to derive lookup table address: %w", err)
+	}
+
+	first := addresses[:min(lookupTableAddressesPerExtend, len(addresses))]
+	instructions := []solana.Instruction{createLookupTableInstructionFrom(table, signer.PublicKey(), recentSlot, bump)}
+	if len(first) > 0 {
+		instructions = append(instructions, extendLookupTableInstructionFrom(table, signer.PublicKey(), first))
+	}
+
+	if err := b.sendLookupTableTransaction(instructions, signer); err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to create lookup table: %w", err)
+	}
+
+	if err := b.ExtendLookupTable(table, signer, addresses[len(first):]); err != nil {
+		return table, err
+	}
+
+	return table, nil
+}
+
+// ExtendLookupTable appends addresses to a table the signer is the authority of
+func (b *BlockchainClient) ExtendLookupTable(table solana.PublicKey, signer Signer, addresses []solana.PublicKey) error {
+	for start := 0; start < len(addresses); start += lookupTableAddressesPerExtend {
+		batch := addresses[start:min(start+lookupTableAddressesPerExtend, len(addresses))]
+
+		if err := b.sendLookupTableTransaction([]solana.Instruction{extendLookupTableInstructionFrom(table, signer.PublicKey(), batch)}, signer); err != nil {
+			return fmt.Errorf("failed to extend lookup table %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+func (b *BlockchainClient) sendLookupTableTransaction(instructions []solana.Instruction, signer Signer) error {
+	blockhash, err := b.blockhashCache.Latest()
+	if err != nil {
+		return fmt.Errorf("failed to get recent blockhash: %w", err)
+	}
+
+	// nothing is racing us here, so the minimum fee will do
+	sent, err := b.signAndSendPlain(instructions, b.feeEstimator.minPriorityFee, blockhash, signer)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.ConfirmTransaction(sent.signature, sent.lastValidBlockHeight, b.confirmationCommitment); err != nil {
+		return fmt.Errorf("confirmation failed: %w", err)
+	}
+
+	return nil
+}
+
+// SetLookupTable loads an address lookup table so buys and sells are built as v0 transactions that
+// reference its addresses. Addresses added to the table later need another call to be picked up.
+func (b *BlockchainClient) SetLookupTable(table solana.PublicKey) error {
+	state, err := addresslookuptable.GetAddressLookupTable(context.Background(), b.client, table)
+	if err != nil {
+		return fmt.Errorf("failed to get lookup table %s: %w", table, err)
+	}
+	if !state.IsActive() {
+		return fmt.Errorf("lookup table %s is deactivated", table)
+	}
+
+	b.lookupTables = map[solana.PublicKey]solana.PublicKeySlice{table: state.Addresses}
+	return nil
+}
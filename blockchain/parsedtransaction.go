@@ -0,0 +1,235 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// ParsedTransaction is a confirmed transaction with every account index resolved to its key and
+// balances totalled per owner, so amounts are worked out in one place
+type ParsedTransaction struct {
+	Signature            solana.Signature
+	Slot                 uint64
+	BlockTime            int64
+	Err                  interface{} // nil if the transaction succeeded
+	Fee                  uint64      // lamports
+	ComputeUnitsConsumed uint64
+	FeePayer             solana.PublicKey
+	AccountKeys          []solana.PublicKey // static keys, then lookup table keys loaded writable, then readonly
+	Instructions         []ParsedInstruction
+	LogMessages          []string
+
+	PreSolBalances    map[solana.PublicKey]uint64                      // lamports by account
+	PostSolBalances   map[solana.PublicKey]uint64                      // lamports by account
+	PreTokenBalances  map[solana.PublicKey]map[solana.PublicKey]uint64 // raw amount by owner, then mint
+	PostTokenBalances map[solana.PublicKey]map[solana.PublicKey]uint64 // raw amount by owner, then mint
+	TokenDecimals     map[solana.PublicKey]uint8                       // by mint
+
+	SolDeltas   map[solana.PublicKey]int64                      // lamports by account, the fee included for the payer
+	TokenDeltas map[solana.PublicKey]map[solana.PublicKey]int64 // raw amount by owner, then mint
+}
+
+type ParsedInstruction struct {
+	ProgramID solana.PublicKey
+	Accounts  []solana.PublicKey
+	Data      []byte
+	Inner     []ParsedInstruction // instructions this one invoked, in execution order
+}
+
+func (t *ParsedTransaction) Failed() bool {
+	return t.Err != nil
+}
+
+// SolDelta is how many lamports the account gained, negative if it spent them
+func (t *ParsedTransaction) SolDelta(account solana.PublicKey) int64 {
+	return t.SolDeltas[account]
+}
+
+// TokenDelta is how many raw tokens of the mint the owner gained across its token accounts,
+// negative if it sold or sent them
+func (t *ParsedTransaction) TokenDelta(owner solana.PublicKey, mint solana.PublicKey) int64 {
+	return t.TokenDeltas[owner][mint]
+}
+
+// InstructionsFor lists every instruction run by the program, top level and inner, in execution order
+func (t *ParsedTransaction) InstructionsFor(program solana.PublicKey) []ParsedInstruction {
+	found := []ParsedInstruction{}
+	var walk func(instructions []ParsedInstruction)
+	walk = func(instructions []ParsedInstruction) {
+		for _, instruction := range instructions {
+			if instruction.ProgramID.Equals(program) {
+				found = append(found, instruction)
+			}
+			walk(instruction.Inner)
+		}
+	}
+	walk(t.Instructions)
+
+	return found
+}
+
+// GetTransaction fetches and parses a confirmed transaction, legacy or v0
+func (b *BlockchainClient) GetTransaction(signature string) (*ParsedTransaction, error) {
+	sig, err := solana.SignatureFromBase58(signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	maxSupportedTransactionVersion := uint64(0)
+	result, err := b.client.GetTransaction(context.Background(), sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxSupportedTransactionVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction %s: %w", signature, err)
+	}
+
+	return parseTransaction(result)
+}
+
+func parseTransaction(result *rpc.GetTransactionResult) (*ParsedTransaction, error) {
+	if result.Transaction == nil || result.Meta == nil {
+		return nil, fmt.Errorf("transaction has no body or meta")
+	}
+
+	tx, err := result.Transaction.GetTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	if len(tx.Signatures) == 0 || len(tx.Message.AccountKeys) == 0 {
+		return nil, fmt.Errorf("transaction has no signatures or account keys")
+	}
+
+	meta := result.Meta
+	accountKeys := append(append(append([]solana.PublicKey{}, tx.Message.AccountKeys...), meta.LoadedAddresses.Writable...), meta.LoadedAddresses.ReadOnly...)
+
+	key := func(index uint16) (solana.PublicKey, error) {
+		if int(index) >= len(accountKeys) {
+			return solana.PublicKey{}, fmt.Errorf("account index %d out of range of %d keys", index, len(accountKeys))
+		}
+		return accountKeys[index], nil
+	}
+	instruction := func(compiled solana.CompiledInstruction) (ParsedInstruction, error) {
+		programID, err := key(compiled.ProgramIDIndex)
+		if err != nil {
+			return ParsedInstruction{}, err
+		}
+		parsed := ParsedInstruction{ProgramID: programID, Data: compiled.Data}
+		for _, index := range compiled.Accounts {
+			account, err := key(index)
+			if err != nil {
+				return ParsedInstruction{}, err
+			}
+			parsed.Accounts = append(parsed.Accounts, account)
+		}
+		return parsed, nil
+	}
+
+	parsed := &ParsedTransaction{
+		Signature:   tx.Signatures[0],
+		Slot:        result.Slot,
+		Err:         meta.Err,
+		Fee:         meta.Fee,
+		FeePayer:    accountKeys[0],
+		AccountKeys: accountKeys,
+		LogMessages: meta.LogMessages,
+	}
+	if result.BlockTime != nil {
+		parsed.BlockTime = int64(*result.BlockTime)
+	}
+	if meta.ComputeUnitsConsumed != nil {
+		parsed.ComputeUnitsConsumed = *meta.ComputeUnitsConsumed
+	}
+
+	for _, compiled := range tx.Message.Instructions {
+		top, err := instruction(compiled)
+		if err != nil {
+			return nil, err
+		}
+		parsed.Instructions = append(parsed.Instructions, top)
+	}
+	for _, inner := range meta.InnerInstructions {
+		if int(inner.Index) >= len(parsed.Instructions) {
+			return nil, fmt.Errorf("inner instructions for missing instruction %d", inner.Index)
+		}
+		for _, compiled := range inner.Instructions {
+			cpi, err := instruction(compiled)
+			if err != nil {
+				return nil, err
+			}
+			parsed.Instructions[inner.Index].Inner = append(parsed.Instructions[inner.Index].Inner, cpi)
+		}
+	}
+
+	parsed.PreSolBalances = solBalancesFrom(accountKeys, meta.PreBalances)
+	parsed.PostSolBalances = solBalancesFrom(accountKeys, meta.PostBalances)
+	parsed.SolDeltas = map[solana.PublicKey]int64{}
+	for account, post := range parsed.PostSolBalances {
+		if delta := int64(post) - int64(parsed.PreSolBalances[account]); delta != 0 {
+			parsed.SolDeltas[account] = delta
+		}
+	}
+
+	parsed.TokenDecimals = map[solana.PublicKey]uint8{}
+	if parsed.PreTokenBalances, err = tokenBalancesFrom(meta.PreTokenBalances, parsed.TokenDecimals); err != nil {
+		return nil, err
+	}
+	if parsed.PostTokenBalances, err = tokenBalancesFrom(meta.PostTokenBalances, parsed.TokenDecimals); err != nil {
+		return nil, err
+	}
+	parsed.TokenDeltas = map[solana.PublicKey]map[solana.PublicKey]int64{}
+	// an account closed in the transaction only shows up in the pre balances
+	for _, balances := range []map[solana.PublicKey]map[solana.PublicKey]uint64{parsed.PreTokenBalances, parsed.PostTokenBalances} {
+		for owner, mints := range balances {
+			for mint := range mints {
+				delta := int64(parsed.PostTokenBalances[owner][mint]) - int64(parsed.PreTokenBalances[owner][mint])
+				if delta == 0 {
+					continue
+				}
+				if parsed.TokenDeltas[owner] == nil {
+					parsed.TokenDeltas[owner] = map[solana.PublicKey]int64{}
+				}
+				parsed.TokenDeltas[owner][mint] = delta
+			}
+		}
+	}
+
+	return parsed, nil
+}
+
+func solBalancesFrom(accountKeys []solana.PublicKey, lamports []uint64) map[solana.PublicKey]uint64 {
+	balances := make(map[solana.PublicKey]uint64, len(lamports))
+	for i, balance := range lamports {
+		if i < len(accountKeys) {
+			balances[accountKeys[i]] = balance
+		}
+	}
+	return balances
+}
+
+// tokenBalancesFrom totals the token account balances by owner and mint, noting each mint's decimals
+func tokenBalancesFrom(tokenBalances []rpc.TokenBalance, decimals map[solana.PublicKey]uint8) (map[solana.PublicKey]map[solana.PublicKey]uint64, error) {
+	balances := map[solana.PublicKey]map[solana.PublicKey]uint64{}
+	for _, balance := range tokenBalances {
+		if balance.Owner == nil || balance.UiTokenAmount == nil {
+			continue
+		}
+
+		amount, err := strconv.ParseUint(balance.UiTokenAmount.Amount, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token amount %q: %w", balance.UiTokenAmount.Amount, err)
+		}
+
+		if balances[*balance.Owner] == nil {
+			balances[*balance.Owner] = map[solana.PublicKey]uint64{}
+		}
+		balances[*balance.Owner][balance.Mint] += amount
+		decimals[balance.Mint] = balance.UiTokenAmount.Decimals
+	}
+	return balances, nil
+}
@@ -0,0 +1,55 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+var bondingCurveSeed = []byte("bonding-curve")
+
+// BondingCurvePDA derives the mint's bonding curve account from the pump program
+func BondingCurvePDA(mint solana.PublicKey) (solana.PublicKey, error) {
+	bondingCurve, _, err := solana.FindProgramAddress([][]byte{bondingCurveSeed, mint.Bytes()}, PUMP_PROGRAM)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to derive bonding curve for %s: %w", mint, err)
+	}
+	return bondingCurve, nil
+}
+
+// BondingCurveAddressesFor derives the bonding curve and the token account it holds the mint's
+// supply in, without asking the pump.fun API
+func BondingCurveAddressesFor(tokenMint string) (bondingCurve solana.PublicKey, associatedBondingCurve solana.PublicKey, err error) {
+	mint, err := solana.PublicKeyFromBase58(tokenMint)
+	if err != nil {
+		return solana.PublicKey{}, solana.PublicKey{}, fmt.Errorf("invalid mint address: %w", err)
+	}
+
+	bondingCurve, err = BondingCurvePDA(mint)
+	if err != nil {
+		return solana.PublicKey{}, solana.PublicKey{}, err
+	}
+
+	associatedBondingCurve, _, err = solana.FindAssociatedTokenAddress(bondingCurve, mint)
+	if err != nil {
+		return solana.PublicKey{}, solana.PublicKey{}, fmt.Errorf("failed to derive associated bonding curve for %s: %w", mint, err)
+	}
+
+	return bondingCurve, associatedBondingCurve, nil
+}
+
+// BuyTokenWithSolForMint buys like BuyTokenWithSol but derives the bonding curve accounts locally
+func (b *BlockchainClient) BuyTokenWithSolForMint(
+	tokenMint string,
+	solAmount float64,
+	slippageBps uint64,
+	signer Signer,
+	opts *TradeOptions,
+) (*BuyTokenResult, error) {
+	bondingCurve, associatedBondingCurve, err := BondingCurveAddressesFor(tokenMint)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.BuyTokenWithSol(tokenMint, bondingCurve.String(), associatedBondingCurve.String(), solAmount, slippageBps, signer, opts)
+}
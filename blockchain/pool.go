@@ -0,0 +1,301 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
+	"github.com/ethanhosier/pumpfun-trade-bot/utils"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	raydiumPoolSize          = 752
+	raydiumSwapBaseIn        = 9
+	raydiumPoolBaseMintAt    = 400
+	raydiumPoolQuoteMintAt   = 432
+	openBookMarketSize       = 388
+	tokenAccountAmountOffset = 64
+	createIdempotentATA      = 1
+)
+
+var (
+	RAYDIUM_AMM_PROGRAM   = solana.MustPublicKeyFromBase58("675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8")
+	RAYDIUM_AMM_AUTHORITY = solana.MustPublicKeyFromBase58("5Q544fKrFoe6tsEbD7S8EmxGTJYAKtTVhAW5Q5pge4j1")
+)
+
+// raydiumPool is the part of a Raydium AMM v4 pool account a swap needs. Pump.fun migrates coins
+// with the coin as the base and wrapped SOL as the quote, but either way round is handled.
+type raydiumPool struct {
+	address           solana.PublicKey
+	swapFeeNumerator  uint64
+	swapFeeDenom      uint64
+	needTakePnlBase   uint64 // owed to the protocol, so not part of the tradeable reserves
+	needTakePnlQuote  uint64
+	baseVault         solana.PublicKey
+	quoteVault        solana.PublicKey
+	baseMint          solana.PublicKey
+	quoteMint         solana.PublicKey
+	openOrders        solana.PublicKey
+	marketID          solana.PublicKey
+	marketProgramID   solana.PublicKey
+	targetOrders      solana.PublicKey
+	marketBids        solana.PublicKey
+	marketAsks        solana.PublicKey
+	marketEventQueue  solana.PublicKey
+	marketBaseVault   solana.PublicKey
+	marketQuoteVault  solana.PublicKey
+	marketVaultSigner solana.PublicKey
+}
+
+func decodeRaydiumPool(address solana.PublicKey, data []byte) (*raydiumPool, error) {
+	if len(data) != raydiumPoolSize {
+		return nil, fmt.Errorf("account %s is not a raydium pool: %d bytes", address, len(data))
+	}
+
+	key := func(offset int) solana.PublicKey {
+		return solana.PublicKeyFromBytes(data[offset : offset+32])
+	}
+	return &raydiumPool{
+		address:          address,
+		swapFeeNumerator: binary.LittleEndian.Uint64(data[176:184]),
+		swapFeeDenom:     binary.LittleEndian.Uint64(data[184:192]),
+		needTakePnlBase:  binary.LittleEndian.Uint64(data[192:200]),
+		needTakePnlQuote: binary.LittleEndian.Uint64(data[200:208]),
+		baseVault:        key(336),
+		quoteVault:       key(368),
+		baseMint:         key(raydiumPoolBaseMintAt),
+		quoteMint:        key(raydiumPoolQuoteMintAt),
+		openOrders:       key(496),
+		marketID:         key(528),
+		marketProgramID:  key(560),
+		targetOrders:     key(592),
+	}, nil
+}
+
+// withMarket adds the OpenBook market accounts the swap instruction still takes
+func (p *raydiumPool) withMarket(data []byte) error {
+	if len(data) != openBookMarketSize {
+		return fmt.Errorf("account %s is not an openbook market: %d bytes", p.marketID, len(data))
+	}
+
+	key := func(offset int) solana.PublicKey {
+		return solana.PublicKeyFromBytes(data[offset : offset+32])
+	}
+	p.marketBaseVault = key(117)
+	p.marketQuoteVault = key(165)
+	p.marketEventQueue = key(253)
+	p.marketBids = key(285)
+	p.marketAsks = key(317)
+
+	vaultSigner, err := solana.CreateProgramAddress([][]byte{p.marketID.Bytes(), data[45:53]}, p.marketProgramID)
+	if err != nil {
+		return fmt.Errorf("failed to derive vault signer of market %s: %w", p.marketID, err)
+	}
+	p.marketVaultSigner = vaultSigner
+
+	return nil
+}
+
+// quoterFor prices selling mint for SOL from the vault balances
+func (p *raydiumPool) quoterFor(mint solana.PublicKey, baseVaultBalance uint64, quoteVaultBalance uint64) (*curve.Pool, error) {
+	baseReserves := baseVaultBalance - min(baseVaultBalance, p.needTakePnlBase)
+	quoteReserves := quoteVaultBalance - min(quoteVaultBalance, p.needTakePnlQuote)
+
+	switch {
+	case p.baseMint.Equals(mint) && p.quoteMint.Equals(SOL):
+		return curve.NewPool(quoteReserves, baseReserves, p.swapFeeNumerator, p.swapFeeDenom), nil
+	case p.quoteMint.Equals(mint) && p.baseMint.Equals(SOL):
+		return curve.NewPool(baseReserves, quoteReserves, p.swapFeeNumerator, p.swapFeeDenom), nil
+	default:
+		return nil, fmt.Errorf("pool %s does not pair %s with SOL", p.address, mint)
+	}
+}
+
+func raydiumSwapBaseInInstructionFrom(pool *raydiumPool, source solana.PublicKey, destination solana.PublicKey, owner solana.PublicKey, amountIn uint64, minimumAmountOut uint64) solana.Instruction {
+	data := []byte{raydiumSwapBaseIn}
+	data = binary.LittleEndian.AppendUint64(data, amountIn)
+	data = binary.LittleEndian.AppendUint64(data, minimumAmountOut)
+
+	return solana.NewInstruction(RAYDIUM_AMM_PROGRAM, []*solana.AccountMeta{
+		solana.NewAccountMeta(SYSTEM_TOKEN_PROGRAM, false, false),   // SYSTEM_TOKEN_PROGRAM
+		solana.NewAccountMeta(pool.address, true, false),            // Pool
+		solana.NewAccountMeta(RAYDIUM_AMM_AUTHORITY, false, false),  // RAYDIUM_AMM_AUTHORITY
+		solana.NewAccountMeta(pool.openOrders, true, false),         // Open Orders
+		solana.NewAccountMeta(pool.targetOrders, true, false),       // Target Orders
+		solana.NewAccountMeta(pool.baseVault, true, false),          // Pool Base Vault
+		solana.NewAccountMeta(pool.quoteVault, true, false),         // Pool Quote Vault
+		solana.NewAccountMeta(pool.marketProgramID, false, false),   // Market Program
+		solana.NewAccountMeta(pool.marketID, true, false),           // Market
+		solana.NewAccountMeta(pool.marketBids, true, false),         // Market Bids
+		solana.NewAccountMeta(pool.marketAsks, true, false),         // Market Asks
+		solana.NewAccountMeta(pool.marketEventQueue, true, false),   // Market Event Queue
+		solana.NewAccountMeta(pool.marketBaseVault, true, false),    // Market Base Vault
+		solana.NewAccountMeta(pool.marketQuoteVault, true, false),   // Market Quote Vault
+		solana.NewAccountMeta(pool.marketVaultSigner, false, false), // Market Vault Signer
+		solana.NewAccountMeta(source, true, false),                  // User Source Token Account
+		solana.NewAccountMeta(destination, true, false),             // User Destination Token Account
+		solana.NewAccountMeta(owner, false, true),                   // User
+	}, data)
+}
+
+// createIdempotentATAInstructionFrom opens the owner's token account for the mint unless it already exists
+func createIdempotentATAInstructionFrom(ata solana.PublicKey, owner solana.PublicKey, mint solana.PublicKey) solana.Instruction {
+	return solana.NewInstruction(SYSTEM_ASSOCIATED_TOKEN_ACCOUNT_PROGRAM, []*solana.AccountMeta{
+		solana.NewAccountMeta(owner, true, true),                  // Payer
+		solana.NewAccountMeta(ata, true, false),                   // Associated Token Account
+		solana.NewAccountMeta(owner, false, false),                // Owner
+		solana.NewAccountMeta(mint, false, false),                 // Mint
+		solana.NewAccountMeta(SYSTEM_PROGRAM, false, false),       // SYSTEM_PROGRAM
+		solana.NewAccountMeta(SYSTEM_TOKEN_PROGRAM, false, false), // SYSTEM_TOKEN_PROGRAM
+	}, []byte{createIdempotentATA})
+}
+
+// RaydiumPoolFor finds the Raydium pool a graduated coin migrated to
+func (b *BlockchainClient) RaydiumPoolFor(tokenMint string) (solana.PublicKey, error) {
+	mint, err := solana.PublicKeyFromBase58(tokenMint)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("invalid mint address: %w", err)
+	}
+
+	// only the pool addresses are needed, not their data
+	noData := uint64(0)
+	for _, offsets := range [][2]uint64{{raydiumPoolBaseMintAt, raydiumPoolQuoteMintAt}, {raydiumPoolQuoteMintAt, raydiumPoolBaseMintAt}} {
+		pools, err := b.client.GetProgramAccountsWithOpts(context.Background(), RAYDIUM_AMM_PROGRAM, &rpc.GetProgramAccountsOpts{
+			Commitment: rpc.CommitmentConfirmed,
+			Encoding:   solana.EncodingBase64,
+			DataSlice:  &rpc.DataSlice{Offset: &noData, Length: &noData},
+			Filters: []rpc.RPCFilter{
+				{DataSize: raydiumPoolSize},
+				{Memcmp: &rpc.RPCFilterMemcmp{Offset: offsets[0], Bytes: mint.Bytes()}},
+				{Memcmp: &rpc.RPCFilterMemcmp{Offset: offsets[1], Bytes: SOL.Bytes()}},
+			},
+		})
+		if err != nil {
+			return solana.PublicKey{}, fmt.Errorf("failed to find raydium pool for %s: %w", mint, err)
+		}
+		if len(pools) > 0 {
+			return pools[0].Pubkey, nil
+		}
+	}
+
+	return solana.PublicKey{}, fmt.Errorf("no raydium pool found for %s", mint)
+}
+
+// SellTokenOnPool sells part or all of the token account balance of a graduated coin through its
+// Raydium pool, receiving SOL. The wrapped SOL is unwrapped in the same transaction.
+func (b *BlockchainClient) SellTokenOnPool(
+	tokenMint string,
+	poolAddress string,
+	associatedTokenAccountAddress string,
+	sellAmount SellAmount,
+	slippageBps uint64,
+	signer Signer,
+	opts *TradeOptions,
+) (*SellTokenResult, error) {
+	if opts == nil {
+		opts = &TradeOptions{}
+	}
+
+	blockhashTask := utils.DoAsync(b.blockhashCache.Latest)
+
+	mintPubKey, err := solana.PublicKeyFromBase58(tokenMint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token mint: %w", err)
+	}
+	poolPubKey, err := solana.PublicKeyFromBase58(poolAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pool address: %w", err)
+	}
+	ataPubKey, err := solana.PublicKeyFromBase58(associatedTokenAccountAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid associated token account address: %w", err)
+	}
+
+	poolData, err := b.accountsData(poolPubKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool: %w", err)
+	}
+	pool, err := decodeRaydiumPool(poolPubKey, poolData[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// the market, both vaults and our balance in one round trip
+	data, err := b.accountsData(pool.marketID, pool.baseVault, pool.quoteVault, ataPubKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool accounts: %w", err)
+	}
+	if err := pool.withMarket(data[0]); err != nil {
+		return nil, err
+	}
+	for i, account := range data[1:] {
+		if len(account) < tokenAccountAmountOffset+8 {
+			return nil, fmt.Errorf("token account %d of pool %s too small: %d bytes", i, poolPubKey, len(account))
+		}
+	}
+	amountOf := func(account []byte) uint64 {
+		return binary.LittleEndian.Uint64(account[tokenAccountAmountOffset:])
+	}
+	balance := amountOf(data[3])
+
+	quoter, err := pool.quoterFor(mintPubKey, amountOf(data[1]), amountOf(data[2]))
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := sellAmount.quote(balance, quoter)
+	if err != nil {
+		return nil, err
+	}
+	minSolOutput := curve.MinSolOutput(quote.SolAmount, slippageBps)
+
+	wsolAccount, _, err := solana.FindAssociatedTokenAddress(signer.PublicKey(), SOL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find wrapped SOL account: %w", err)
+	}
+
+	instructions := []solana.Instruction{
+		createIdempotentATAInstructionFrom(wsolAccount, signer.PublicKey(), SOL),
+		raydiumSwapBaseInInstructionFrom(pool, ataPubKey, wsolAccount, signer.PublicKey(), quote.TokenAmount, minSolOutput),
+		closeAccountInstructionFrom(wsolAccount, signer.PublicKey()),
+	}
+	closeAccount := opts.CloseAccount && quote.TokenAmount == balance
+	if closeAccount {
+		instructions = append(instructions, closeAccountInstructionFrom(ataPubKey, signer.PublicKey()))
+	}
+
+	priorityFee := b.feeEstimator.EstimateForAttempt(b.sellFeePercentile, opts.Attempt, RAYDIUM_AMM_PROGRAM, poolPubKey)
+
+	blockhash, err := utils.GetAsync(blockhashTask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent blockhash: %w", err)
+	}
+
+	sent, err := b.signAndSend(instructions, priorityFee, blockhash, signer, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send pool sell transaction: %w", err)
+	}
+
+	confirmation, err := b.ConfirmTransaction(sent.signature, sent.lastValidBlockHeight, b.confirmationCommitment)
+	if err != nil {
+		return nil, fmt.Errorf("transaction confirmation failed: %w", err)
+	}
+
+	result := &SellTokenResult{TxID: sent.signature.String(), TokenAmount: uiTokenAmount(quote.TokenAmount), RemainingTokenAmount: uiTokenAmount(balance - quote.TokenAmount), AccountClosed: closeAccount, MinSolOutput: minSolOutput, Quote: quote, Confirmation: confirmation, ComputeUnitsConsumed: sent.computeUnitsConsumed, Pool: poolAddress}
+
+	tx, err := b.GetTransactionDataWithRetries(sent.signature.String(), fillFetchRetries)
+	if err == nil {
+		result.Fill, err = poolFillFrom(tx, signer.PublicKey(), mintPubKey, RAYDIUM_AMM_AUTHORITY, quote)
+	}
+	if err != nil {
+		log.Printf("Failed to reconcile pool sell fill for %s: %v", sent.signature, err)
+	} else {
+		result.TokenAmount = result.Fill.UiTokenAmount()
+	}
+
+	return result, nil
+}
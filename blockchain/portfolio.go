@@ -0,0 +1,209 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
+	"github.com/ethanhosier/pumpfun-trade-bot/utils"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const maxAccountsPerRequest = 100 // RPC nodes reject getMultipleAccounts calls for more keys
+
+// Holding is one token account with a balance. Pump.fun holdings still on their bonding curve
+// are valued at what selling the whole holding would return.
+type Holding struct {
+	TokenAccount string
+	Mint         string
+	Amount       uint64 // raw token units
+	Decimals     uint8
+	UiAmount     float64
+	BondingCurve string  // empty if the mint is not a pump.fun token
+	Complete     bool    // the bonding curve has graduated, so it cannot be valued from the curve
+	ValueInSol   float64 // zero unless valued from an active bonding curve
+}
+
+type Portfolio struct {
+	Wallet     string
+	SolBalance float64
+	Holdings   []Holding // most valuable first
+}
+
+// TotalValueInSol is the SOL balance plus every holding valued from its bonding curve
+func (p *Portfolio) TotalValueInSol() float64 {
+	total := p.SolBalance
+	for _, holding := range p.Holdings {
+		total += holding.ValueInSol
+	}
+	return total
+}
+
+// String summarises the portfolio one holding per line, for notifications and the CLI
+func (p *Portfolio) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %.4f SOL, %.4f SOL total\n", p.Wallet, p.SolBalance, p.TotalValueInSol())
+
+	for _, holding := range p.Holdings {
+		switch {
+		case holding.BondingCurve == "":
+			fmt.Fprintf(&sb, "%s: %v\n", holding.Mint, holding.UiAmount)
+		case holding.Complete:
+			fmt.Fprintf(&sb, "%s: %v (graduated)\n", holding.Mint, holding.UiAmount)
+		default:
+			fmt.Fprintf(&sb, "%s: %v ~ %.4f SOL\n", holding.Mint, holding.UiAmount, holding.ValueInSol)
+		}
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// Portfolio lists the wallet's SOL balance and every token account holding a balance
+func (b *BlockchainClient) Portfolio(walletAddress string) (*Portfolio, error) {
+	wallet, err := solana.PublicKeyFromBase58(walletAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet address: %w", err)
+	}
+
+	balanceTask := utils.DoAsync(func() (*rpc.GetBalanceResult, error) {
+		return b.client.GetBalance(context.Background(), wallet, rpc.CommitmentConfirmed)
+	})
+
+	holdings, err := b.holdingsFor(wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := utils.GetAsync(balanceTask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	if err := b.valueHoldings(holdings); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(holdings, func(i, j int) bool { return holdings[i].ValueInSol > holdings[j].ValueInSol })
+
+	return &Portfolio{Wallet: walletAddress, SolBalance: float64(balance.Value) / lamportsPerSol, Holdings: holdings}, nil
+}
+
+func (b *BlockchainClient) holdingsFor(wallet solana.PublicKey) ([]Holding, error) {
+	result, err := b.client.GetTokenAccountsByOwner(
+		context.Background(),
+		wallet,
+		&rpc.GetTokenAccountsConfig{ProgramId: &SYSTEM_TOKEN_PROGRAM},
+		&rpc.GetTokenAccountsOpts{Commitment: rpc.CommitmentConfirmed, Encoding: solana.EncodingJSONParsed},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token accounts: %w", err)
+	}
+
+	holdings := []Holding{}
+	for _, tokenAccount := range result.Value {
+		var parsed struct {
+			Parsed struct {
+				Info struct {
+					Mint        string `json:"mint"`
+					TokenAmount struct {
+						Amount   string  `json:"amount"`
+						Decimals uint8   `json:"decimals"`
+						UiAmount float64 `json:"uiAmount"`
+					} `json:"tokenAmount"`
+				} `json:"info"`
+			} `json:"parsed"`
+		}
+		if err := json.Unmarshal(tokenAccount.Account.Data.GetRawJSON(), &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse token account %s: %w", tokenAccount.Pubkey, err)
+		}
+
+		info := parsed.Parsed.Info
+		amount, err := strconv.ParseUint(info.TokenAmount.Amount, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse token amount of %s: %w", tokenAccount.Pubkey, err)
+		}
+		if amount == 0 {
+			continue
+		}
+
+		holdings = append(holdings, Holding{
+			TokenAccount: tokenAccount.Pubkey.String(),
+			Mint:         info.Mint,
+			Amount:       amount,
+			Decimals:     info.TokenAmount.Decimals,
+			UiAmount:     info.TokenAmount.UiAmount,
+		})
+	}
+
+	return holdings, nil
+}
+
+// valueHoldings fetches every holding's bonding curve in as few round trips as the RPC allows;
+// mints without one are not pump.fun tokens and are left unvalued
+func (b *BlockchainClient) valueHoldings(holdings []Holding) error {
+	if len(holdings) == 0 {
+		return nil
+	}
+
+	accounts := []solana.PublicKey{PUMP_GLOBAL}
+	for _, holding := range holdings {
+		bondingCurve, _, err := BondingCurveAddressesFor(holding.Mint)
+		if err != nil {
+			return err
+		}
+		accounts = append(accounts, bondingCurve)
+	}
+
+	values := make([]*rpc.Account, 0, len(accounts))
+	for start := 0; start < len(accounts); start += maxAccountsPerRequest {
+		batch := accounts[start:min(start+maxAccountsPerRequest, len(accounts))]
+
+		result, err := b.client.GetMultipleAccountsWithOpts(context.Background(), batch, &rpc.GetMultipleAccountsOpts{
+			Encoding:   solana.EncodingBase64,
+			Commitment: rpc.CommitmentConfirmed,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get bonding curves: %w", err)
+		}
+		if len(result.Value) != len(batch) {
+			return fmt.Errorf("expected %d bonding curve accounts, got %d", len(batch), len(result.Value))
+		}
+		values = append(values, result.Value...)
+	}
+	if values[0] == nil {
+		return fmt.Errorf("failed to get global account")
+	}
+
+	global, err := curve.DecodeGlobal(values[0].Data.GetBinary())
+	if err != nil {
+		return fmt.Errorf("failed to decode global account: %w", err)
+	}
+
+	for i, account := range values[1:] {
+		if account == nil || !account.Owner.Equals(PUMP_PROGRAM) {
+			continue
+		}
+
+		bondingCurve, err := curve.DecodeBondingCurve(account.Data.GetBinary())
+		if err != nil {
+			continue
+		}
+
+		holdings[i].BondingCurve = accounts[i+1].String()
+		holdings[i].Complete = bondingCurve.Complete
+		if bondingCurve.Complete {
+			continue
+		}
+
+		if quote, err := bondingCurve.Curve(global.FeeBasisPoints).SellExactTokensIn(holdings[i].Amount); err == nil {
+			holdings[i].ValueInSol = float64(quote.SolAmount) / lamportsPerSol
+		}
+	}
+
+	return nil
+}
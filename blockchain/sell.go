@@ -0,0 +1,131 @@
+package blockchain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
+)
+
+type sellMode int
+
+const (
+	sellAll sellMode = iota
+	sellTokens
+	sellPercent
+	sellForSol
+)
+
+// SellAmount says how much of a holding to sell; build one with SellAll, SellTokens,
+// SellPercent or SellForSol
+type SellAmount struct {
+	mode    sellMode
+	tokens  uint64  // raw token units
+	percent float64 // 0-100
+	sol     uint64  // lamports
+}
+
+// SellAll sells the whole token account balance
+func SellAll() SellAmount {
+	return SellAmount{mode: sellAll}
+}
+
+// SellTokens sells exactly tokens raw token units
+func SellTokens(tokens uint64) SellAmount {
+	return SellAmount{mode: sellTokens, tokens: tokens}
+}
+
+// SellPercent sells percent (0-100] of the token account balance
+func SellPercent(percent float64) SellAmount {
+	return SellAmount{mode: sellPercent, percent: percent}
+}
+
+// SellForSol sells the fewest tokens that return at least solAmount after fees, e.g. to take
+// the initial capital out and let the rest ride
+func SellForSol(solAmount float64) SellAmount {
+	return SellAmount{mode: sellForSol, sol: uint64(solAmount * lamportsPerSol)}
+}
+
+// ParseSellAmount reads a sell amount as written in config: "all", a percentage such as "50%", a
+// SOL target such as "0.05sol", or a whole number of raw token units
+func ParseSellAmount(amount string) (SellAmount, error) {
+	amount = strings.ToLower(strings.TrimSpace(amount))
+
+	switch {
+	case amount == "" || amount == "all":
+		return SellAll(), nil
+	case strings.HasSuffix(amount, "%"):
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(amount, "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return SellAmount{}, fmt.Errorf("sell percentage %q must be in (0, 100]", amount)
+		}
+		return SellPercent(percent), nil
+	case strings.HasSuffix(amount, "sol"):
+		solAmount, err := strconv.ParseFloat(strings.TrimSuffix(amount, "sol"), 64)
+		if err != nil || solAmount <= 0 {
+			return SellAmount{}, fmt.Errorf("sell SOL target %q must be a positive amount", amount)
+		}
+		return SellForSol(solAmount), nil
+	default:
+		tokens, err := strconv.ParseUint(amount, 10, 64)
+		if err != nil || tokens == 0 {
+			return SellAmount{}, fmt.Errorf("sell amount %q is not all, a percentage, a SOL target or a token count", amount)
+		}
+		return SellTokens(tokens), nil
+	}
+}
+
+func (s SellAmount) String() string {
+	switch s.mode {
+	case sellTokens:
+		return fmt.Sprintf("%d tokens", s.tokens)
+	case sellPercent:
+		return fmt.Sprintf("%.2f%%", s.percent)
+	case sellForSol:
+		return fmt.Sprintf("%d lamports", s.sol)
+	default:
+		return "all"
+	}
+}
+
+// sellQuoter is a bonding curve or, once the coin has graduated, its AMM pool
+type sellQuoter interface {
+	SellExactTokensIn(tokens uint64) (*curve.Quote, error)
+	SellExactSolOut(solOut uint64, maxTokens uint64) (*curve.Quote, error)
+}
+
+// quote resolves the amount against the current balance and curve or pool
+func (s SellAmount) quote(balance uint64, c sellQuoter) (*curve.Quote, error) {
+	if balance == 0 {
+		return nil, fmt.Errorf("no tokens to sell: %w", ErrNotEnoughTokens)
+	}
+
+	var quote *curve.Quote
+	var err error
+
+	switch s.mode {
+	case sellAll:
+		quote, err = c.SellExactTokensIn(balance)
+	case sellTokens:
+		quote, err = c.SellExactTokensIn(s.tokens)
+	case sellPercent:
+		if s.percent <= 0 || s.percent > 100 {
+			return nil, fmt.Errorf("sell percentage %.2f must be in (0, 100]", s.percent)
+		}
+		quote, err = c.SellExactTokensIn(uint64(float64(balance) * s.percent / 100))
+	case sellForSol:
+		quote, err = c.SellExactSolOut(s.sol, balance)
+	default:
+		return nil, fmt.Errorf("unknown sell mode %d", s.mode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to quote sell of %s: %w", s, err)
+	}
+
+	if quote.TokenAmount > balance {
+		return nil, fmt.Errorf("selling %s needs %d tokens, holding %d: %w", s, quote.TokenAmount, balance, ErrNotEnoughTokens)
+	}
+
+	return quote, nil
+}
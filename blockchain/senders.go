@@ -0,0 +1,218 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/google/uuid"
+)
+
+const (
+	BundleInvalid = "Invalid"
+	BundlePending = "Pending"
+	BundleFailed  = "Failed"
+	BundleLanded  = "Landed"
+
+	// a bundle whose blockhash has expired can no longer land, so is no longer worth tracking
+	bundleExpiry = 2 * time.Minute
+)
+
+// TxSender submits signed buy and sell transactions. Instructions returns anything the sender
+// needs added to the transaction before it is signed, such as a tip.
+type TxSender interface {
+	Instructions(payer solana.PublicKey) []solana.Instruction
+	Send(tx *solana.Transaction, skipPreflight bool) (solana.Signature, error)
+}
+
+// rpcSender sends through the regular RPC node's sendTransaction
+type rpcSender struct {
+	client *rpc.Client
+}
+
+func (s *rpcSender) Instructions(payer solana.PublicKey) []solana.Instruction {
+	return nil
+}
+
+func (s *rpcSender) Send(tx *solana.Transaction, skipPreflight bool) (solana.Signature, error) {
+	return s.client.SendTransactionWithOpts(context.Background(), tx, rpc.TransactionOpts{
+		SkipPreflight:       skipPreflight,
+		PreflightCommitment: rpc.CommitmentProcessed,
+	})
+}
+
+// BundleSender tips a block-engine tip account from inside the transaction and submits it as a
+// single transaction bundle, so it lands all or nothing ahead of unbundled competitors
+type BundleSender struct {
+	endpoint    string // block-engine JSON-RPC url, e.g. https://mainnet.block-engine.jito.wtf/api/v1/bundles
+	tipAccount  solana.PublicKey
+	tipLamports uint64
+	httpClient  *http.Client
+
+	mu      sync.Mutex
+	bundles map[solana.Signature]sentBundle // by transaction signature, until landed, failed or expired
+}
+
+type sentBundle struct {
+	id     string
+	sentAt time.Time
+}
+
+type BundleStatus struct {
+	BundleID   string
+	Status     string // one of the Bundle* constants
+	LandedSlot uint64
+}
+
+func NewBundleSender(endpoint string, tipAccount solana.PublicKey, tipLamports uint64) *BundleSender {
+	return &BundleSender{
+		endpoint:    endpoint,
+		tipAccount:  tipAccount,
+		tipLamports: tipLamports,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		bundles:     make(map[solana.Signature]sentBundle),
+	}
+}
+
+func (s *BundleSender) Instructions(payer solana.PublicKey) []solana.Instruction {
+	return []solana.Instruction{
+		system.NewTransferInstruction(s.tipLamports, payer, s.tipAccount).Build(),
+	}
+}
+
+// Send submits the transaction as a bundle. Block engines never run preflight, so skipPreflight
+// is ignored.
+func (s *BundleSender) Send(tx *solana.Transaction, skipPreflight bool) (solana.Signature, error) {
+	if len(tx.Signatures) == 0 {
+		return solana.Signature{}, fmt.Errorf("transaction is not signed")
+	}
+
+	encoded, err := tx.MarshalBinary()
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("failed to encode transaction: %w", err)
+	}
+
+	var bundleID string
+	err = s.call("sendBundle", []interface{}{
+		[]string{base64.StdEncoding.EncodeToString(encoded)},
+		map[string]string{"encoding": "base64"},
+	}, &bundleID)
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("failed to send bundle: %w", err)
+	}
+
+	sig := tx.Signatures[0]
+	now := time.Now()
+	s.mu.Lock()
+	for sent, bundle := range s.bundles {
+		if now.Sub(bundle.sentAt) > bundleExpiry {
+			delete(s.bundles, sent)
+		}
+	}
+	s.bundles[sig] = sentBundle{id: bundleID, sentAt: now}
+	s.mu.Unlock()
+
+	return sig, nil
+}
+
+// BundleID returns the id of the bundle the transaction was sent in, until it lands, fails or expires
+func (s *BundleSender) BundleID(sig solana.Signature) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bundle, ok := s.bundles[sig]
+	return bundle.id, ok
+}
+
+// BundleStatus asks the block engine how a recently sent bundle is doing, and stops tracking it
+// once it has landed or failed
+func (s *BundleSender) BundleStatus(bundleID string) (*BundleStatus, error) {
+	var result struct {
+		Value []struct {
+			BundleID   string `json:"bundle_id"`
+			Status     string `json:"status"`
+			LandedSlot uint64 `json:"landed_slot"`
+		} `json:"value"`
+	}
+
+	if err := s.call("getInflightBundleStatuses", []interface{}{[]string{bundleID}}, &result); err != nil {
+		return nil, fmt.Errorf("failed to get bundle status: %w", err)
+	}
+
+	// unknown bundles come back as an empty value, or one marked invalid
+	if len(result.Value) == 0 {
+		return &BundleStatus{BundleID: bundleID, Status: BundleInvalid}, nil
+	}
+
+	status := result.Value[0]
+	if status.Status == BundleLanded || status.Status == BundleFailed {
+		s.forget(bundleID)
+	}
+	return &BundleStatus{BundleID: status.BundleID, Status: status.Status, LandedSlot: status.LandedSlot}, nil
+}
+
+func (s *BundleSender) forget(bundleID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sig, bundle := range s.bundles {
+		if bundle.id == bundleID {
+			delete(s.bundles, sig)
+		}
+	}
+}
+
+func (s *BundleSender) call(method string, params []interface{}, result interface{}) error {
+	requestBody := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      uuid.New().String(),
+		"method":  method,
+		"params":  params,
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	resp, err := s.httpClient.Post(s.endpoint, "application/json", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("failed to parse response (status %d): %v", resp.StatusCode, err)
+	}
+
+	if response.Error != nil {
+		return fmt.Errorf("block engine error %d: %s", response.Error.Code, response.Error.Message)
+	}
+
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("failed to parse result: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package blockchain
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/gagliardetto/solana-go"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keystoreVersion = 1
+	keystoreScryptN = 1 << 17
+	keystoreScryptR = 8
+	keystoreScryptP = 1
+	keystoreKeySize = 32 // aes-256
+)
+
+// Signer signs transactions for a wallet. Keys are loaded once when the signer is built.
+type Signer interface {
+	PublicKey() solana.PublicKey
+	Sign(message []byte) (solana.Signature, error)
+}
+
+type privateKeySigner struct {
+	key solana.PrivateKey
+}
+
+func (s *privateKeySigner) PublicKey() solana.PublicKey {
+	return s.key.PublicKey()
+}
+
+func (s *privateKeySigner) Sign(message []byte) (solana.Signature, error) {
+	return s.key.Sign(message)
+}
+
+// String shows the public key so a logged signer never leaks the private one
+func (s *privateKeySigner) String() string {
+	return s.key.PublicKey().String()
+}
+
+// NewSigner wraps an already loaded private key
+func NewSigner(key solana.PrivateKey) (Signer, error) {
+	if !key.IsValid() {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	return &privateKeySigner{key}, nil
+}
+
+// SignerFromBase58 parses a base58 private key, as exported by Phantom and friends
+func SignerFromBase58(privateKey string) (Signer, error) {
+	key, err := solana.PrivateKeyFromBase58(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return NewSigner(key)
+}
+
+// SignerFromEnv parses the base58 private key held in the named environment variable
+func SignerFromEnv(name string) (Signer, error) {
+	privateKey := os.Getenv(name)
+	if privateKey == "" {
+		return nil, fmt.Errorf("%s is not set", name)
+	}
+	return SignerFromBase58(privateKey)
+}
+
+// SignerFromKeypairFile reads a Solana CLI keypair file, a JSON array of the 64 key bytes
+func SignerFromKeypairFile(path string) (Signer, error) {
+	key, err := solana.PrivateKeyFromSolanaKeygenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keypair file: %w", err)
+	}
+	return NewSigner(key)
+}
+
+// keystore is a private key encrypted with AES-256-GCM under a scrypt derived key
+type keystore struct {
+	Version    int              `json:"version"`
+	PublicKey  solana.PublicKey `json:"publicKey"`
+	Salt       []byte           `json:"salt"`
+	N          int              `json:"n"`
+	R          int              `json:"r"`
+	P          int              `json:"p"`
+	Nonce      []byte           `json:"nonce"`
+	Ciphertext []byte           `json:"ciphertext"`
+}
+
+// SignerFromKeystore decrypts a keystore file written by WriteKeystore
+func SignerFromKeystore(path string, passphrase string) (Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+
+	var ks keystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, fmt.Errorf("failed to parse keystore: %w", err)
+	}
+	if ks.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d", ks.Version)
+	}
+
+	gcm, err := keystoreCipher(passphrase, ks.Salt, ks.N, ks.R, ks.P)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := gcm.Open(nil, ks.Nonce, ks.Ciphertext, ks.PublicKey.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore, wrong passphrase?")
+	}
+
+	signer, err := NewSigner(solana.PrivateKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if !signer.PublicKey().Equals(ks.PublicKey) {
+		return nil, fmt.Errorf("keystore key does not match public key %s", ks.PublicKey)
+	}
+
+	return signer, nil
+}
+
+// WriteKeystore encrypts the private key under the passphrase and writes it to path, readable
+// only by the owner
+func WriteKeystore(path string, key solana.PrivateKey, passphrase string) error {
+	return writeKeystore(path, key, passphrase, keystoreScryptN)
+}
+
+func writeKeystore(path string, key solana.PrivateKey, passphrase string, scryptN int) error {
+	if !key.IsValid() {
+		return fmt.Errorf("invalid private key")
+	}
+	if passphrase == "" {
+		return fmt.Errorf("refusing to write a keystore with an empty passphrase")
+	}
+
+	ks := keystore{Version: keystoreVersion, PublicKey: key.PublicKey(), N: scryptN, R: keystoreScryptR, P: keystoreScryptP}
+
+	ks.Salt = make([]byte, 32)
+	if _, err := rand.Read(ks.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, err := keystoreCipher(passphrase, ks.Salt, ks.N, ks.R, ks.P)
+	if err != nil {
+		return err
+	}
+
+	ks.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(ks.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// the public key is authenticated so it cannot be swapped without the passphrase
+	ks.Ciphertext = gcm.Seal(nil, ks.Nonce, key, ks.PublicKey.Bytes())
+
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keystore: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+
+	return nil
+}
+
+func keystoreCipher(passphrase string, salt []byte, n int, r int, p int) (cipher.AEAD, error) {
+	derived, err := scrypt.Key([]byte(passphrase), salt, n, r, p, keystoreKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive keystore key: %w", err)
+	}
+
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,314 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gorilla/websocket"
+)
+
+type subscriptionOptions struct {
+	pingInterval         time.Duration
+	staleStreamTimeout   time.Duration // no message or pong for this long means the stream is dead
+	minReconnectBackoff  time.Duration
+	maxReconnectBackoff  time.Duration
+	maxReconnectAttempts int
+	backfillLimit        int
+}
+
+func defaultSubscriptionOptions() subscriptionOptions {
+	return subscriptionOptions{
+		pingInterval:         15 * time.Second,
+		staleStreamTimeout:   45 * time.Second,
+		minReconnectBackoff:  500 * time.Millisecond,
+		maxReconnectBackoff:  30 * time.Second,
+		maxReconnectAttempts: 20,
+		backfillLimit:        100,
+	}
+}
+
+// signatureSet remembers the most recent signatures per wallet so that the live stream and
+// backfill never emit the same transaction twice for a wallet, while a transaction touching two
+// tracked wallets is still emitted once for each
+type signatureSet struct {
+	seen  map[walletSignature]bool
+	order []walletSignature
+	size  int
+}
+
+type walletSignature struct {
+	wallet    string
+	signature string
+}
+
+func newSignatureSet(size int) *signatureSet {
+	return &signatureSet{seen: make(map[walletSignature]bool), size: size}
+}
+
+// add returns false if the wallet's signature was already in the set
+func (s *signatureSet) add(wallet string, signature string) bool {
+	key := walletSignature{wallet, signature}
+	if s.seen[key] {
+		return false
+	}
+
+	if len(s.order) >= s.size {
+		delete(s.seen, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.seen[key] = true
+	s.order = append(s.order, key)
+	return true
+}
+
+func (b *BlockchainClient) connectAndSubscribe(walletAddresses []string) (*websocket.Conn, map[int]string, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(b.wsUrl, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to WebSocket: %v", err)
+	}
+
+	subscriptionToWallet := make(map[int]string)
+
+	for _, wallet := range walletAddresses {
+		subscriptionID, err := b.subscribeToWalletTransactions(conn, wallet)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		subscriptionToWallet[subscriptionID] = wallet
+	}
+
+	return conn, subscriptionToWallet, nil
+}
+
+// transactionSignaturesLoop forwards notifications until done is closed, reconnecting and
+// backfilling missed signatures whenever the socket drops. An error is only sent once
+// reconnecting has been given up on.
+func (b *BlockchainClient) transactionSignaturesLoop(conn *websocket.Conn, walletAddresses []string, subscriptionToWallet map[int]string, done <-chan interface{}, walletTransactionSignaturesCh chan<- WalletTransactionSignature, errCh chan<- error) {
+	subscribedAt := time.Now()
+	seen := newSignatureSet(channelBufferSize)
+	lastSeen := make(map[string]string)
+
+	// emit returns false once done is closed, so a consumer that stopped reading cannot block shutdown
+	emit := func(wts WalletTransactionSignature) bool {
+		if !seen.add(wts.Wallet, wts.Signature) {
+			return true
+		}
+		lastSeen[wts.Wallet] = wts.Signature
+
+		select {
+		case walletTransactionSignaturesCh <- wts:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	for {
+		err := b.readTransactionSignatures(conn, done, subscriptionToWallet, emit)
+		conn.Close()
+		if err == nil {
+			log.Println("Done signal received, exiting transaction signatures loop")
+			return
+		}
+
+		log.Printf("Websocket subscription dropped, reconnecting: %v", err)
+		conn, subscriptionToWallet, err = b.reconnect(walletAddresses, done)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		if conn == nil {
+			return
+		}
+
+		for _, wallet := range walletAddresses {
+			missed, err := b.backfillTransactionSignatures(wallet, lastSeen[wallet], subscribedAt)
+			if err != nil {
+				log.Printf("Failed to backfill signatures for wallet %s: %v", wallet, err)
+				continue
+			}
+			for _, wts := range missed {
+				if !emit(wts) {
+					log.Println("Done signal received, exiting transaction signatures loop")
+					return
+				}
+			}
+		}
+	}
+}
+
+// readTransactionSignatures reads one connection until it fails or goes stale, returning nil
+// only when done is closed
+func (b *BlockchainClient) readTransactionSignatures(conn *websocket.Conn, done <-chan interface{}, subscriptionToWallet map[int]string, emit func(WalletTransactionSignature) bool) error {
+	opts := b.subscriptionOptions
+
+	conn.SetReadDeadline(time.Now().Add(opts.staleStreamTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(opts.staleStreamTimeout))
+	})
+
+	msgCh := make(chan []byte, channelBufferSize)
+	readErrCh := make(chan error, 1)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				readErrCh <- fmt.Errorf("websocket read error: %v", err)
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(opts.staleStreamTimeout))
+
+			select {
+			case msgCh <- message:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	pingTicker := time.NewTicker(opts.pingInterval)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case err := <-readErrCh:
+			return err
+		case <-pingTicker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(subscribeReadTimeout)); err != nil {
+				return fmt.Errorf("websocket ping error: %v", err)
+			}
+		case message := <-msgCh:
+			var response LogResponse
+			if err := json.Unmarshal(message, &response); err != nil {
+				log.Printf("Failed to parse websocket message: %v", err)
+				continue
+			}
+
+			if response.Method == "logsNotification" {
+				emitted := emit(WalletTransactionSignature{
+					Signature:  response.Params.Result.Value.Signature,
+					Wallet:     subscriptionToWallet[response.Params.Subscription],
+					Logs:       response.Params.Result.Value.Logs,
+					Failed:     response.Params.Result.Value.Err.InstructionError != nil,
+					Slot:       uint64(response.Params.Result.Context.Slot),
+					ReceivedAt: time.Now(),
+				})
+				if !emitted {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// reconnect redials with exponential backoff, returning a nil connection if done is closed first
+func (b *BlockchainClient) reconnect(walletAddresses []string, done <-chan interface{}) (*websocket.Conn, map[int]string, error) {
+	opts := b.subscriptionOptions
+	backoff := opts.minReconnectBackoff
+
+	var err error
+	for attempt := 1; attempt <= opts.maxReconnectAttempts; attempt++ {
+		select {
+		case <-done:
+			return nil, nil, nil
+		case <-time.After(backoff):
+		}
+
+		var conn *websocket.Conn
+		var subscriptionToWallet map[int]string
+		conn, subscriptionToWallet, err = b.connectAndSubscribe(walletAddresses)
+		if err == nil {
+			log.Printf("Websocket reconnected after %d attempts", attempt)
+			return conn, subscriptionToWallet, nil
+		}
+
+		log.Printf("Websocket reconnect attempt %d failed: %v", attempt, err)
+		backoff = min(backoff*2, opts.maxReconnectBackoff)
+	}
+
+	return nil, nil, fmt.Errorf("failed to reconnect websocket after %d attempts: %w", opts.maxReconnectAttempts, err)
+}
+
+// backfillTransactionSignatures returns, oldest first, the wallet's signatures newer than
+// lastSeen, or newer than since if nothing has been seen for the wallet yet
+func (b *BlockchainClient) backfillTransactionSignatures(wallet string, lastSeen string, since time.Time) ([]WalletTransactionSignature, error) {
+	walletPubKey, err := solana.PublicKeyFromBase58(wallet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet address: %v", err)
+	}
+
+	limit := b.subscriptionOptions.backfillLimit
+	opts := &rpc.GetSignaturesForAddressOpts{
+		Limit:      &limit,
+		Commitment: rpc.CommitmentConfirmed,
+	}
+	if lastSeen != "" {
+		opts.Until, err = solana.SignatureFromBase58(lastSeen)
+		if err != nil {
+			return nil, fmt.Errorf("invalid last seen signature: %v", err)
+		}
+	}
+
+	signatures, err := b.client.GetSignaturesForAddressWithOpts(context.Background(), walletPubKey, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signatures for address: %w", err)
+	}
+
+	if len(signatures) >= limit {
+		log.Printf("Backfill for wallet %s hit the %d signature limit, older missed signatures are dropped", wallet, limit)
+	}
+
+	var missed []WalletTransactionSignature
+	for i := len(signatures) - 1; i >= 0; i-- {
+		signature := signatures[i]
+		if lastSeen == "" && signature.BlockTime != nil && signature.BlockTime.Time().Before(since) {
+			continue
+		}
+
+		// consumers such as the token launch loop decode from the logs a notification would carry
+		logs, err := b.transactionLogs(signature.Signature)
+		if err != nil {
+			log.Printf("Failed to get logs of backfilled transaction %s: %v", signature.Signature, err)
+		}
+
+		missed = append(missed, WalletTransactionSignature{
+			Wallet:     wallet,
+			Signature:  signature.Signature.String(),
+			Logs:       logs,
+			Failed:     signature.Err != nil,
+			Slot:       signature.Slot,
+			ReceivedAt: time.Now(),
+		})
+	}
+
+	return missed, nil
+}
+
+// transactionLogs fetches only the log messages of a confirmed transaction
+func (b *BlockchainClient) transactionLogs(signature solana.Signature) ([]string, error) {
+	maxSupportedTransactionVersion := uint64(0)
+	result, err := b.client.GetTransaction(context.Background(), signature, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxSupportedTransactionVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction %s: %w", signature, err)
+	}
+	if result.Meta == nil {
+		return nil, fmt.Errorf("transaction %s has no meta", signature)
+	}
+
+	return result.Meta.LogMessages, nil
+}
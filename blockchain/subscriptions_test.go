@@ -0,0 +1,415 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gorilla/websocket"
+)
+
+const testWallet = "J4bzyKJKZKKz2HUGFiq3DMRaxEaw6MxKf8rjGTvpkqaU"
+
+// fakeSolanaServer stands in for both the websocket and JSON-RPC endpoints. Each websocket
+// connection is handed to the next session func in turn.
+type fakeSolanaServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	sessions []func(conn *websocket.Conn)
+	results  map[string]interface{}                              // JSON-RPC result by method
+	handlers map[string]func(params json.RawMessage) interface{} // results that depend on the params
+	params   map[string]json.RawMessage                          // params of the last JSON-RPC call by method
+}
+
+func newFakeSolanaServer(t *testing.T, sessions ...func(conn *websocket.Conn)) *fakeSolanaServer {
+	f := &fakeSolanaServer{sessions: sessions, results: make(map[string]interface{}), handlers: make(map[string]func(json.RawMessage) interface{}), params: make(map[string]json.RawMessage)}
+	upgrader := websocket.Upgrader{}
+
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			f.mu.Lock()
+			if len(f.sessions) == 0 {
+				f.mu.Unlock()
+				http.Error(w, "no more sessions", http.StatusServiceUnavailable)
+				return
+			}
+			session := f.sessions[0]
+			f.sessions = f.sessions[1:]
+			f.mu.Unlock()
+
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("failed to upgrade: %v", err)
+				return
+			}
+			defer conn.Close()
+			session(conn)
+			return
+		}
+
+		var request struct {
+			ID     interface{}     `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.params[request.Method] = request.Params
+		result := f.results[request.Method]
+		if handler, ok := f.handlers[request.Method]; ok {
+			result = handler(request.Params)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": result})
+	}))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func (f *fakeSolanaServer) client() *BlockchainClient {
+	client := rpc.New(f.URL)
+
+	return &BlockchainClient{
+		client:         client,
+		txSender:       &rpcSender{client},
+		blockhashCache: NewBlockhashCache(client, rpc.CommitmentConfirmed, 50*time.Millisecond),
+		wsUrl:          "ws" + strings.TrimPrefix(f.URL, "http"),
+		subscriptionOptions: subscriptionOptions{
+			pingInterval:         50 * time.Millisecond,
+			staleStreamTimeout:   300 * time.Millisecond,
+			minReconnectBackoff:  10 * time.Millisecond,
+			maxReconnectBackoff:  50 * time.Millisecond,
+			maxReconnectAttempts: 3,
+			backfillLimit:        10,
+		},
+		confirmationOptions: confirmationOptions{
+			pollInterval:           20 * time.Millisecond,
+			subscribedPollInterval: 100 * time.Millisecond,
+			timeout:                2 * time.Second,
+		},
+	}
+}
+
+func (f *fakeSolanaServer) lastParams(method string) json.RawMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.params[method]
+}
+
+func (f *fakeSolanaServer) setResult(method string, result interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[method] = result
+}
+
+// setHandler answers method from its params, for results that differ between calls
+func (f *fakeSolanaServer) setHandler(method string, handler func(params json.RawMessage) interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[method] = handler
+}
+
+func acceptSubscription(t *testing.T, conn *websocket.Conn, subscriptionID int) {
+	var request map[string]interface{}
+	if err := conn.ReadJSON(&request); err != nil {
+		t.Errorf("failed to read subscription: %v", err)
+		return
+	}
+	conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": subscriptionID})
+}
+
+func sendNotification(conn *websocket.Conn, subscriptionID int, signature string) {
+	conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "logsNotification",
+		"params": map[string]interface{}{
+			"subscription": subscriptionID,
+			"result": map[string]interface{}{
+				"context": map[string]interface{}{"slot": 1},
+				"value":   map[string]interface{}{"signature": signature, "err": nil, "logs": []string{}},
+			},
+		},
+	})
+}
+
+func testSignature(b byte) string {
+	return solana.Signature{b}.String()
+}
+
+func receiveSignatures(t *testing.T, ch <-chan WalletTransactionSignature, n int) []string {
+	var signatures []string
+	for len(signatures) < n {
+		select {
+		case wts := <-ch:
+			if wts.Wallet != testWallet {
+				t.Errorf("Expected wallet %s, got %s", testWallet, wts.Wallet)
+			}
+			signatures = append(signatures, wts.Signature)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timed out waiting for signatures, got %v", signatures)
+		}
+	}
+	return signatures
+}
+
+func TestSubscriptionReconnectsAndBackfills(t *testing.T) {
+	first, missed, second := testSignature(1), testSignature(2), testSignature(3)
+	released := make(chan struct{})
+
+	server := newFakeSolanaServer(t,
+		func(conn *websocket.Conn) {
+			acceptSubscription(t, conn, 7)
+			sendNotification(conn, 7, first)
+			<-released
+		},
+		func(conn *websocket.Conn) {
+			acceptSubscription(t, conn, 8)
+			// replayed by the backfill too, so must only be emitted once
+			sendNotification(conn, 8, missed)
+			sendNotification(conn, 8, second)
+			conn.ReadMessage()
+		},
+	)
+	server.setResult("getSignaturesForAddress", []map[string]interface{}{
+		{"signature": missed, "slot": 2, "err": nil},
+	})
+
+	done := make(chan interface{})
+	defer close(done)
+
+	ch, errCh, err := server.client().SubscribeToWalletsTransactionSignatures([]string{testWallet}, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := receiveSignatures(t, ch, 1); got[0] != first {
+		t.Fatalf("Expected %s, got %v", first, got)
+	}
+	close(released)
+
+	got := receiveSignatures(t, ch, 2)
+	if !(got[0] == missed && got[1] == second) {
+		t.Errorf("Expected [%s %s], got %v", missed, second, got)
+	}
+
+	select {
+	case wts := <-ch:
+		t.Errorf("Expected no duplicate signatures, got %s", wts.Signature)
+	case err := <-errCh:
+		t.Errorf("Expected no error, got %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestSubscriptionEmitsSharedTransactionPerWallet(t *testing.T) {
+	otherWallet := solana.NewWallet().PublicKey().String()
+	shared := testSignature(7)
+
+	server := newFakeSolanaServer(t, func(conn *websocket.Conn) {
+		acceptSubscription(t, conn, 1)
+		acceptSubscription(t, conn, 2)
+		sendNotification(conn, 1, shared)
+		sendNotification(conn, 2, shared)
+		conn.ReadMessage()
+	})
+
+	done := make(chan interface{})
+	defer close(done)
+
+	ch, _, err := server.client().SubscribeToWalletsTransactionSignatures([]string{testWallet, otherWallet}, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wallets := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case wts := <-ch:
+			if wts.Signature != shared {
+				t.Errorf("Expected %s, got %s", shared, wts.Signature)
+			}
+			wallets[wts.Wallet] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Expected the shared transaction once per wallet, got it for %v", wallets)
+		}
+	}
+	if !wallets[testWallet] || !wallets[otherWallet] {
+		t.Errorf("Expected the shared transaction for both wallets, got %v", wallets)
+	}
+}
+
+func TestSubscriptionExitsWithUnreadSignatures(t *testing.T) {
+	server := newFakeSolanaServer(t, func(conn *websocket.Conn) {
+		acceptSubscription(t, conn, 1)
+		sendNotification(conn, 1, testSignature(6))
+		conn.ReadMessage()
+	})
+	client := server.client()
+
+	conn, subscriptionToWallet, err := client.connectAndSubscribe([]string{testWallet})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan interface{})
+	exited := make(chan struct{})
+	go func() {
+		// never read, as if the consumer had stopped
+		client.transactionSignaturesLoop(conn, []string{testWallet}, subscriptionToWallet, done, make(chan WalletTransactionSignature), make(chan error, 1))
+		close(exited)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	close(done)
+
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the loop to exit once done is closed")
+	}
+}
+
+func TestBackfillFetchesLogs(t *testing.T) {
+	missed := testSignature(5)
+	logs := []string{"Program 6EF8rrecthR5Dkzon8Nwu78hRvfCKubJ14M5uBEwF6P invoke [1]", "Program log: Instruction: Create"}
+
+	server := newFakeSolanaServer(t)
+	server.setResult("getSignaturesForAddress", []map[string]interface{}{
+		{"signature": missed, "slot": 5, "err": nil},
+	})
+	server.setResult("getTransaction", map[string]interface{}{
+		"slot": 5,
+		"meta": map[string]interface{}{"err": nil, "fee": 5000, "logMessages": logs},
+	})
+
+	backfilled, err := server.client().backfillTransactionSignatures(testWallet, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backfilled) != 1 || backfilled[0].Signature != missed || len(backfilled[0].Logs) != len(logs) {
+		t.Errorf("Expected %s backfilled with its logs, got %+v", missed, backfilled)
+	}
+}
+
+func TestSubscriptionDetectsStaleStream(t *testing.T) {
+	fresh := testSignature(4)
+	released := make(chan struct{})
+	defer close(released)
+
+	server := newFakeSolanaServer(t,
+		func(conn *websocket.Conn) {
+			acceptSubscription(t, conn, 1)
+			// never read again, so pings go unanswered
+			<-released
+		},
+		func(conn *websocket.Conn) {
+			acceptSubscription(t, conn, 2)
+			sendNotification(conn, 2, fresh)
+			conn.ReadMessage()
+		},
+	)
+
+	done := make(chan interface{})
+	defer close(done)
+
+	ch, _, err := server.client().SubscribeToWalletsTransactionSignatures([]string{testWallet}, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := receiveSignatures(t, ch, 1); got[0] != fresh {
+		t.Errorf("Expected %s, got %v", fresh, got)
+	}
+}
+
+func TestSubscriptionGivesUpAfterMaxReconnectAttempts(t *testing.T) {
+	server := newFakeSolanaServer(t, func(conn *websocket.Conn) {
+		acceptSubscription(t, conn, 1)
+	})
+
+	done := make(chan interface{})
+	defer close(done)
+
+	_, errCh, err := server.client().SubscribeToWalletsTransactionSignatures([]string{testWallet}, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errCh:
+		if !strings.Contains(err.Error(), "failed to reconnect") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for reconnect to be given up on")
+	}
+}
+
+func TestConfirmTransactionFromSubscription(t *testing.T) {
+	server := newFakeSolanaServer(t, func(conn *websocket.Conn) {
+		acceptSubscription(t, conn, 3)
+		conn.WriteJSON(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  "signatureNotification",
+			"params": map[string]interface{}{
+				"subscription": 3,
+				"result": map[string]interface{}{
+					"context": map[string]interface{}{"slot": 42},
+					"value":   map[string]interface{}{"err": nil},
+				},
+			},
+		})
+		conn.ReadMessage()
+	})
+	server.setResult("getSignatureStatuses", map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": []interface{}{nil}})
+	server.setResult("getBlockHeight", 10)
+
+	confirmation, err := server.client().ConfirmTransaction(solana.Signature{1}, 100, rpc.CommitmentConfirmed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if confirmation.Slot != 42 || confirmation.Commitment != rpc.CommitmentConfirmed {
+		t.Errorf("Unexpected confirmation: %+v", confirmation)
+	}
+}
+
+func TestConfirmTransactionPollsFinalized(t *testing.T) {
+	// no websocket sessions, so confirmation has to fall back to polling
+	server := newFakeSolanaServer(t)
+	server.setResult("getSignatureStatuses", map[string]interface{}{
+		"context": map[string]interface{}{"slot": 50},
+		"value":   []interface{}{map[string]interface{}{"slot": 40, "confirmations": nil, "err": nil, "confirmationStatus": "finalized"}},
+	})
+
+	confirmation, err := server.client().ConfirmTransaction(solana.Signature{1}, 100, rpc.CommitmentConfirmed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if confirmation.Slot != 40 {
+		t.Errorf("Expected slot 40, got %d", confirmation.Slot)
+	}
+}
+
+func TestConfirmTransactionExpired(t *testing.T) {
+	server := newFakeSolanaServer(t)
+	server.setResult("getSignatureStatuses", map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": []interface{}{nil}})
+	server.setResult("getBlockHeight", 101)
+
+	_, err := server.client().ConfirmTransaction(solana.Signature{1}, 100, rpc.CommitmentConfirmed)
+	if !errors.Is(err, ErrTransactionExpired) {
+		t.Errorf("Expected ErrTransactionExpired, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const closeAccountsPerTransaction = 20 // keeps the account keys well inside the transaction size limit
+
+// closeAccountInstructionFrom closes a token account the signer owns, returning its rent to the signer
+func closeAccountInstructionFrom(account solana.PublicKey, owner solana.PublicKey) solana.Instruction {
+	return token.NewCloseAccountInstruction(account, owner, owner, []solana.PublicKey{}).Build()
+}
+
+// EmptyTokenAccounts lists the wallet's token accounts holding no tokens
+func (b *BlockchainClient) EmptyTokenAccounts(owner solana.PublicKey) ([]solana.PublicKey, error) {
+	result, err := b.client.GetTokenAccountsByOwner(
+		context.Background(),
+		owner,
+		&rpc.GetTokenAccountsConfig{ProgramId: &SYSTEM_TOKEN_PROGRAM},
+		&rpc.GetTokenAccountsOpts{Commitment: rpc.CommitmentConfirmed, Encoding: solana.EncodingBase64},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token accounts: %w", err)
+	}
+
+	empty := []solana.PublicKey{}
+	for _, tokenAccount := range result.Value {
+		var account token.Account
+		if err := bin.NewBinDecoder(tokenAccount.Account.Data.GetBinary()).Decode(&account); err != nil {
+			log.Printf("Failed to decode token account %s: %v", tokenAccount.Pubkey, err)
+			continue
+		}
+
+		if account.Amount == 0 {
+			empty = append(empty, tokenAccount.Pubkey)
+		}
+	}
+
+	return empty, nil
+}
+
+// CloseEmptyTokenAccounts closes every zero balance token account the wallet owns, batching the
+// closes into as few transactions as fit, and returns the signatures of the confirmed batches
+func (b *BlockchainClient) CloseEmptyTokenAccounts(signer Signer) ([]string, error) {
+	empty, err := b.EmptyTokenAccounts(signer.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := []string{}
+	for start := 0; start < len(empty); start += closeAccountsPerTransaction {
+		batch := empty[start:min(start+closeAccountsPerTransaction, len(empty))]
+
+		instructions := make([]solana.Instruction, len(batch))
+		for i, account := range batch {
+			instructions[i] = closeAccountInstructionFrom(account, signer.PublicKey())
+		}
+
+		blockhash, err := b.blockhashCache.Latest()
+		if err != nil {
+			return signatures, fmt.Errorf("failed to get recent blockhash: %w", err)
+		}
+
+		// nothing is racing us for these accounts, so the minimum fee will do
+		sent, err := b.signAndSendPlain(instructions, b.feeEstimator.minPriorityFee, blockhash, signer)
+		if err != nil {
+			return signatures, fmt.Errorf("failed to send close accounts transaction: %w", err)
+		}
+
+		if _, err := b.ConfirmTransaction(sent.signature, sent.lastValidBlockHeight, b.confirmationCommitment); err != nil {
+			return signatures, fmt.Errorf("close accounts confirmation failed: %w", err)
+		}
+
+		signatures = append(signatures, sent.signature.String())
+	}
+
+	return signatures, nil
+}
@@ -0,0 +1,172 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	simulationComputeUnitLimit = 400_000
+	computeUnitMarginBps       = 2_000 // headroom over simulated usage
+)
+
+// TradeOptions tunes how a buy or sell is sent; nil means the defaults
+type TradeOptions struct {
+	SkipSimulation bool
+	Attempt        int  // retries pay an escalated priority fee
+	CloseAccount   bool // on a sell of the whole balance, close the token account and reclaim its rent
+}
+
+type SimulationResult struct {
+	ComputeUnitsConsumed uint64
+	Logs                 []string
+}
+
+// sentTransaction is a signed transaction that has been handed to the network
+type sentTransaction struct {
+	signature            solana.Signature
+	transaction          *solana.Transaction
+	lastValidBlockHeight uint64
+	computeUnitLimit     uint32
+	computeUnitsConsumed uint64 // zero if simulation was skipped
+	sentAt               time.Time
+}
+
+// SimulateTransaction runs the transaction against the latest bank state. A failed simulation
+// returns its decoded program error alongside the logs and compute units.
+func (b *BlockchainClient) SimulateTransaction(tx *solana.Transaction) (*SimulationResult, error) {
+	result, err := b.client.SimulateTransactionWithOpts(context.Background(), tx, &rpc.SimulateTransactionOpts{
+		Commitment:             rpc.CommitmentProcessed,
+		ReplaceRecentBlockhash: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate transaction: %w", err)
+	}
+
+	simulation := &SimulationResult{Logs: result.Value.Logs}
+	if result.Value.UnitsConsumed != nil {
+		simulation.ComputeUnitsConsumed = *result.Value.UnitsConsumed
+	}
+
+	if result.Value.Err != nil {
+		return simulation, decodeTransactionError(tx, result.Value.Err)
+	}
+
+	return simulation, nil
+}
+
+// signAndSend prefixes the compute budget instructions, simulates unless told not to so the
+// compute unit limit fits the transaction, then signs it and hands it to the client's TxSender
+func (b *BlockchainClient) signAndSend(instructions []solana.Instruction, priorityFee uint64, blockhash *rpc.LatestBlockhashResult, signer Signer, opts *TradeOptions) (*sentTransaction, error) {
+	return b.signAndSendWith(b.txSender, instructions, priorityFee, blockhash, signer, opts)
+}
+
+// signAndSendPlain is signAndSend through the RPC node rather than the client's TxSender, for
+// housekeeping transactions that nothing is racing and so should not pay a bundle tip
+func (b *BlockchainClient) signAndSendPlain(instructions []solana.Instruction, priorityFee uint64, blockhash *rpc.LatestBlockhashResult, signer Signer) (*sentTransaction, error) {
+	return b.signAndSendWith(&rpcSender{b.client}, instructions, priorityFee, blockhash, signer, nil)
+}
+
+func (b *BlockchainClient) signAndSendWith(sender TxSender, instructions []solana.Instruction, priorityFee uint64, blockhash *rpc.LatestBlockhashResult, signer Signer, opts *TradeOptions) (*sentTransaction, error) {
+	if opts == nil {
+		opts = &TradeOptions{}
+	}
+
+	// the sender's extra instructions, such as a bundle tip, are part of what gets simulated
+	instructions = append(instructions, sender.Instructions(signer.PublicKey())...)
+
+	sent := &sentTransaction{lastValidBlockHeight: blockhash.LastValidBlockHeight, computeUnitLimit: computeUnitLimit}
+
+	if !opts.SkipSimulation {
+		tx, err := b.signedTransaction(instructions, simulationComputeUnitLimit, priorityFee, blockhash.Blockhash, signer)
+		if err != nil {
+			return nil, err
+		}
+
+		simulation, err := b.SimulateTransaction(tx)
+		if err != nil {
+			return nil, fmt.Errorf("simulation failed: %w", err)
+		}
+
+		sent.computeUnitsConsumed = simulation.ComputeUnitsConsumed
+		sent.computeUnitLimit = computeUnitLimitFor(simulation.ComputeUnitsConsumed)
+	}
+
+	tx, err := b.signedTransaction(instructions, sent.computeUnitLimit, priorityFee, blockhash.Blockhash, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	// preflight would only repeat the simulation we just ran
+	sent.signature, err = sender.Send(tx, !opts.SkipSimulation)
+	sent.sentAt = time.Now()
+	if err != nil {
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
+	}
+	sent.transaction = tx
+
+	return sent, nil
+}
+
+func (b *BlockchainClient) signedTransaction(instructions []solana.Instruction, computeUnitLimit uint32, priorityFee uint64, blockhash solana.Hash, signer Signer) (*solana.Transaction, error) {
+	txOpts := []solana.TransactionOption{solana.TransactionPayer(signer.PublicKey())}
+	if len(b.lookupTables) > 0 {
+		// a v0 transaction referencing the table's accounts by index
+		txOpts = append(txOpts, solana.TransactionAddressTables(b.lookupTables))
+	}
+
+	tx, err := solana.NewTransaction(
+		append(computeBudgetInstructionsFrom(computeUnitLimit, priorityFee), instructions...),
+		blockhash,
+		txOpts...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	if err := signTransaction(tx, signer); err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	return tx, nil
+}
+
+// signTransaction signs a transaction whose only required signer is the payer
+func signTransaction(tx *solana.Transaction, signer Signer) error {
+	signers := tx.Message.Signers()
+	if len(signers) != 1 || !signers[0].Equals(signer.PublicKey()) {
+		return fmt.Errorf("transaction needs signatures from %v, have %s", signers, signer.PublicKey())
+	}
+
+	message, err := tx.Message.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	signature, err := signer.Sign(message)
+	if err != nil {
+		return err
+	}
+
+	tx.Signatures = []solana.Signature{signature}
+	return nil
+}
+
+func computeBudgetInstructionsFrom(computeUnitLimit uint32, priorityFee uint64) []solana.Instruction {
+	return []solana.Instruction{
+		computebudget.NewSetComputeUnitLimitInstruction(computeUnitLimit).Build(),
+		computebudget.NewSetComputeUnitPriceInstruction(priorityFee).Build(),
+	}
+}
+
+func computeUnitLimitFor(computeUnitsConsumed uint64) uint32 {
+	if computeUnitsConsumed == 0 {
+		return computeUnitLimit
+	}
+	return uint32(computeUnitsConsumed * (10_000 + computeUnitMarginBps) / 10_000)
+}
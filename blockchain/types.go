@@ -1,21 +1,11 @@
 package blockchain
 
-type Transaction struct {
-	Meta struct {
-		Err               interface{} `json:"err"`
-		Fee               int64       `json:"fee"`
-		LogMessages       []string    `json:"logMessages"`
-		PostTokenBalances []struct {
-			Mint          string `json:"mint"`
-			UiTokenAmount struct {
-				UiAmount float64 `json:"uiAmount"`
-				Decimals int     `json:"decimals"`
-			} `json:"uiTokenAmount"`
-			Owner string `json:"owner"`
-		} `json:"postTokenBalances"`
-	} `json:"meta"`
-	BlockTime int64 `json:"blockTime"`
-}
+import (
+	"time"
+
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
+	"github.com/gagliardetto/solana-go/rpc"
+)
 
 // LogResponse is the response from the logsNotification method in the websocket connection
 type LogResponse struct {
@@ -39,8 +29,23 @@ type LogResponse struct {
 }
 
 type WalletTransactionSignature struct {
-	Wallet    string `json:"wallet"`
-	Signature string `json:"signature"`
+	Wallet     string    `json:"wallet"`
+	Signature  string    `json:"signature"`
+	Logs       []string  `json:"logs"`
+	Failed     bool      `json:"failed"`
+	Slot       uint64    `json:"slot"`
+	ReceivedAt time.Time `json:"receivedAt"` // when the notification or backfill reached us
+}
+
+// TokenLaunch is a new token created on the pump program
+type TokenLaunch struct {
+	Signature    string
+	Mint         string
+	BondingCurve string
+	Creator      string
+	Name         string
+	Symbol       string
+	Uri          string
 }
 
 type BuyTokenResult struct {
@@ -49,17 +54,30 @@ type BuyTokenResult struct {
 	MaxAmountLampts               uint64
 	AssociatedTokenAccountAddress string
 	TokenAmount                   float64
+	Quote                         *curve.Quote
+	Confirmation                  *Confirmation
+	ComputeUnitsConsumed          uint64    // zero if simulation was skipped
+	Fill                          *Fill     // nil if the landed transaction could not be read back
+	SentAt                        time.Time // when the signed transaction was handed to the network
 }
 
-type TransactionDataInstruction struct {
-	Accounts  []string
-	Data      string
-	ProgramID int
+type SellTokenResult struct {
+	TxID                 string
+	TokenAmount          float64
+	RemainingTokenAmount float64
+	AccountClosed        bool
+	MinSolOutput         uint64
+	Quote                *curve.Quote
+	Confirmation         *Confirmation
+	ComputeUnitsConsumed uint64 // zero if simulation was skipped
+	Fill                 *Fill  // nil if the landed transaction could not be read back
+	Pool                 string // the AMM pool sold through once the coin graduated, empty for curve sells
 }
 
-type TransactionData struct {
-	Signatures      []string
-	Instructions    []TransactionDataInstruction
-	LogMessages     []string
-	RecentBlockhash string
+// Confirmation is a transaction that reached its commitment target
+type Confirmation struct {
+	Signature  string
+	Slot       uint64
+	Commitment rpc.CommitmentType
+	Elapsed    time.Duration
 }
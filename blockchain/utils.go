@@ -1,151 +1,18 @@
 package blockchain
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"math"
 	"time"
 
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
 	"github.com/gagliardetto/solana-go"
 	associatedtokenaccount "github.com/gagliardetto/solana-go/programs/associated-token-account"
-	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
-	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
-type TransactionResponse struct {
-	Result struct {
-		BlockTime int64 `json:"blockTime"`
-		Meta      struct {
-			ComputeUnitsConsumed int      `json:"computeUnitsConsumed"`
-			Fee                  int      `json:"fee"`
-			LogMessages          []string `json:"logMessages"`
-			PreBalances          []int64  `json:"preBalances"`
-			PostBalances         []int64  `json:"postBalances"`
-		} `json:"meta"`
-		Slot        int `json:"slot"`
-		Transaction struct {
-			Message struct {
-				AccountKeys []string `json:"accountKeys"`
-				Header      struct {
-					NumReadonlySignedAccounts   int `json:"numReadonlySignedAccounts"`
-					NumReadonlyUnsignedAccounts int `json:"numReadonlyUnsignedAccounts"`
-					NumRequiredSignatures       int `json:"numRequiredSignatures"`
-				} `json:"header"`
-				Instructions []struct {
-					Accounts    []int  `json:"accounts"`
-					Data        string `json:"data"`
-					ProgramID   int    `json:"programIdIndex"`
-					StackHeight *int   `json:"stackHeight"`
-				} `json:"instructions"`
-				RecentBlockhash string `json:"recentBlockhash"`
-			} `json:"message"`
-			Signatures []string `json:"signatures"`
-		} `json:"transaction"`
-	} `json:"result"`
-}
-
-func (c *BlockchainClient) getTransactionData2(signature string) (*TransactionData, error) {
-	requestBody := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      uuid.New().String(),
-		"method":  "getTransaction",
-		"params": []interface{}{
-			signature,
-		},
-	}
-
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
-	}
-
-	// Make the POST request
-	resp, err := http.Post(
-		restEndpoint+c.apiKey,
-		"application/json",
-		bytes.NewBuffer(jsonBody),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	var tx TransactionResponse
-	if err := json.Unmarshal(body, &tx); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
-	}
-
-	return transactionResponseToTransactionData(&tx), nil
-}
-
-func (c *BlockchainClient) getTransactionData(signature string) (*Transaction, error) {
-	requestBody := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      uuid.New().String(),
-		"method":  "getTransactionWithCompressionInfo",
-		"params": []interface{}{
-			signature,
-		},
-	}
-
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
-	}
-
-	// Make the POST request
-	resp, err := http.Post(
-		restEndpoint+c.apiKey,
-		"application/json",
-		bytes.NewBuffer(jsonBody),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	fmt.Println(string(body))
-
-	var response struct {
-		Result struct {
-			Transaction Transaction `json:"transaction"`
-		} `json:"result"`
-	}
-
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
-	}
-
-	// Check for error response
-	var errorResp struct {
-		Error struct {
-			Code    int    `json:"code"`
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-	if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error.Code != 0 {
-		return nil, fmt.Errorf("Error getting transaction data: %s", errorResp.Error.Message)
-	}
-
-	return &response.Result.Transaction, nil
-}
-
 // //////////////////////////////////////////////////////////////////////////////////////////////////////
 // Helper function to subscribe to a single wallet's transactions
 func (b *BlockchainClient) subscribeToWalletTransactions(conn *websocket.Conn, wallet string) (int, error) {
@@ -192,41 +59,37 @@ func (b *BlockchainClient) subscribeToWalletTransactions(conn *websocket.Conn, w
 	return subscriptionResponse.Result, nil
 }
 
-func (b *BlockchainClient) transactionSignaturesLoop(conn *websocket.Conn, done <-chan interface{}, walletTransactionSignaturesCh chan<- WalletTransactionSignature, errCh chan<- error, subscriptionToWallet map[int]string) {
-	defer conn.Close()
-
-	msgCh := make(chan []byte, channelBufferSize) // maybe this can be unbuffered??
-	msgChDone := make(chan struct{})
-
-	// TODO: this is a bit tecky, make this cleaner so done ch makes this exit immediately
-	go func(doneCh <-chan struct{}) {
-		for {
-			_, message, err := conn.ReadMessage()
-			if err != nil {
-				errCh <- fmt.Errorf("websocket read error: %v", err)
-				return
-			}
-			msgCh <- message
-		}
-	}(msgChDone)
-
+func tokenLaunchesLoop(programTransactionSignaturesCh <-chan WalletTransactionSignature, done <-chan interface{}, tokenLaunchesCh chan<- TokenLaunch) {
 	for {
-		// Check for done signal first
 		select {
 		case <-done:
-			log.Println("Done signal received, exiting transaction signatures loop")
 			return
-		case message := <-msgCh:
-			var response LogResponse
-			if err := json.Unmarshal(message, &response); err != nil {
-				errCh <- fmt.Errorf("websocket read error: %v", err)
-				return
+		case wts := <-programTransactionSignaturesCh:
+			if wts.Failed {
+				continue
+			}
+
+			events, err := PumpEventsFromLogs(wts.Logs)
+			if err != nil {
+				log.Printf("Failed to decode pump events for %s: %v", wts.Signature, err)
+				continue
 			}
 
-			if response.Method == "logsNotification" {
-				walletTransactionSignaturesCh <- WalletTransactionSignature{
-					Signature: response.Params.Result.Value.Signature,
-					Wallet:    subscriptionToWallet[response.Params.Subscription],
+			for _, create := range events.Creates {
+				launch := TokenLaunch{
+					Signature:    wts.Signature,
+					Mint:         create.Mint.String(),
+					BondingCurve: create.BondingCurve.String(),
+					Creator:      create.User.String(),
+					Name:         create.Name,
+					Symbol:       create.Symbol,
+					Uri:          create.Uri,
+				}
+
+				select {
+				case tokenLaunchesCh <- launch:
+				case <-done:
+					return
 				}
 			}
 		}
@@ -235,8 +98,7 @@ func (b *BlockchainClient) transactionSignaturesLoop(conn *websocket.Conn, done
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////
 
-func (b *BlockchainClient) getOrCreateTokenAccountInstruction(tokenMintPubKey solana.PublicKey, ownerPrivateKey solana.PrivateKey) (string, *associatedtokenaccount.Instruction, error) {
-	owner := ownerPrivateKey.PublicKey()
+func (b *BlockchainClient) getOrCreateTokenAccountInstruction(tokenMintPubKey solana.PublicKey, owner solana.PublicKey) (string, *associatedtokenaccount.Instruction, error) {
 
 	// Find the associated token account address
 	ata, _, err := solana.FindAssociatedTokenAddress(owner, tokenMintPubKey)
@@ -261,48 +123,8 @@ func (b *BlockchainClient) getOrCreateTokenAccountInstruction(tokenMintPubKey so
 	return ata.String(), createATAIx, nil
 }
 
-func buyDataFrom(amountInLamports uint64, maxAmountLamports uint64) []byte {
-	discriminator := make([]byte, 8)
-	binary.LittleEndian.PutUint64(discriminator, 16927863322537952870)
-
-	amountData := make([]byte, 8)
-	binary.LittleEndian.PutUint64(amountData, amountInLamports)
-
-	maxAmountData := make([]byte, 8)
-	binary.LittleEndian.PutUint64(maxAmountData, maxAmountLamports)
-
-	return append(discriminator, append(amountData, maxAmountData...)...)
-}
-
-func buyAccountsFrom(
-	mintPubKey solana.PublicKey,
-	bondingCurvePubKey solana.PublicKey,
-	associatedBondingCurvePubKey solana.PublicKey,
-	ataPubKey solana.PublicKey,
-	payerPubKey solana.PublicKey,
-) []*solana.AccountMeta {
-	return []*solana.AccountMeta{
-		solana.NewAccountMeta(PUMP_GLOBAL, false, false),                 // PUMP_GLOBAL
-		solana.NewAccountMeta(PUMP_FEE, true, false),                     // PUMP_FEE
-		solana.NewAccountMeta(mintPubKey, false, false),                  // Mint
-		solana.NewAccountMeta(bondingCurvePubKey, true, false),           // Bonding Curve
-		solana.NewAccountMeta(associatedBondingCurvePubKey, true, false), // Associated Bonding Curve
-		solana.NewAccountMeta(ataPubKey, true, false),                    // Associated Token Account
-		solana.NewAccountMeta(payerPubKey, true, true),                   // Payer
-		solana.NewAccountMeta(SYSTEM_PROGRAM, false, false),              // SYSTEM_PROGRAM
-		solana.NewAccountMeta(SYSTEM_TOKEN_PROGRAM, false, false),        // SYSTEM_TOKEN_PROGRAM
-		solana.NewAccountMeta(SYSTEM_RENT, false, false),                 // SYSTEM_RENT
-		solana.NewAccountMeta(PUMP_EVENT_AUTHORITY, false, false),        // PUMP_EVENT_AUTHORITY
-		solana.NewAccountMeta(PUMP_PROGRAM, false, false),                // PUMP_PROGRAM
-	}
-}
-
-func buyInstructionsFrom(computeUnitLimit uint32, ataCreateInstruction *associatedtokenaccount.Instruction, buyInstruction *solana.GenericInstruction) []solana.Instruction {
-	computeUnitLimitInstruction := computebudget.NewSetComputeUnitLimitInstruction(
-		computeUnitLimit,
-	).Build()
-
-	instructions := []solana.Instruction{computeUnitLimitInstruction}
+func buyInstructionsFrom(ataCreateInstruction *associatedtokenaccount.Instruction, buyInstruction *solana.GenericInstruction) []solana.Instruction {
+	instructions := []solana.Instruction{}
 	if ataCreateInstruction != nil {
 		fmt.Printf("Adding ATA create instruction\n")
 		instructions = append(instructions, ataCreateInstruction)
@@ -329,71 +151,6 @@ func pubKeysFrom(tokenMint string, bondingCurveAddress string, associatedBonding
 	return mintPubKey, bondingCurvePubKey, associatedBondingCurvePubKey, nil
 }
 
-func buyTokenAmountsFrom(solAmount, price, slippage float64) (uint64, uint64, float64, error) {
-	if price == 0 {
-		return 0, 0, 0, fmt.Errorf("price is 0")
-	}
-
-	tokenAmount := solAmount / price
-	amountInLamports := uint64(tokenAmount * lamportsPerSol)
-	maxAmountLamports := uint64(float64(amountInLamports) * (1 + slippage))
-	return amountInLamports, maxAmountLamports, tokenAmount, nil
-}
-
-func sellDataFrom(amount uint64, minSolOutput uint64) []byte {
-	discriminator := make([]byte, 8)
-	binary.LittleEndian.PutUint64(discriminator, 12502976635542562355)
-
-	amountData := make([]byte, 8)
-	binary.LittleEndian.PutUint64(amountData, amount)
-
-	minSolOutputData := make([]byte, 8)
-	binary.LittleEndian.PutUint64(minSolOutputData, minSolOutput)
-
-	return append(discriminator, append(amountData, minSolOutputData...)...)
-}
-
-func sellAccountsFrom(
-	mintPubKey solana.PublicKey,
-	bondingCurvePubKey solana.PublicKey,
-	associatedBondingCurvePubKey solana.PublicKey,
-	ataPubKey solana.PublicKey,
-	payerPubKey solana.PublicKey,
-) []*solana.AccountMeta {
-	return []*solana.AccountMeta{
-		solana.NewAccountMeta(PUMP_GLOBAL, false, false),                             // PUMP_GLOBAL
-		solana.NewAccountMeta(PUMP_FEE, true, false),                                 // PUMP_FEE
-		solana.NewAccountMeta(mintPubKey, false, false),                              // Mint
-		solana.NewAccountMeta(bondingCurvePubKey, true, false),                       // Bonding Curve
-		solana.NewAccountMeta(associatedBondingCurvePubKey, true, false),             // Associated Bonding Curve
-		solana.NewAccountMeta(ataPubKey, true, false),                                // Associated Token Account
-		solana.NewAccountMeta(payerPubKey, true, true),                               // Payer
-		solana.NewAccountMeta(SYSTEM_PROGRAM, false, false),                          // SYSTEM_PROGRAM
-		solana.NewAccountMeta(SYSTEM_ASSOCIATED_TOKEN_ACCOUNT_PROGRAM, false, false), // SYSTEM_ASSOCIATED_TOKEN_ACCOUNT_PROGRAM
-		solana.NewAccountMeta(SYSTEM_TOKEN_PROGRAM, false, false),                    // SYSTEM_TOKEN_PROGRAM
-		solana.NewAccountMeta(PUMP_EVENT_AUTHORITY, false, false),                    // PUMP_EVENT_AUTHORITY
-		solana.NewAccountMeta(PUMP_PROGRAM, false, false),                            // PUMP_PROGRAM
-	}
-}
-
-func transactionResponseToTransactionData(response *TransactionResponse) *TransactionData {
-	var instructions []TransactionDataInstruction
-	for _, instruction := range response.Result.Transaction.Message.Instructions {
-		var accounts []string
-		for _, account := range instruction.Accounts {
-			accounts = append(accounts, response.Result.Transaction.Message.AccountKeys[account])
-		}
-		instructions = append(instructions, TransactionDataInstruction{
-			Accounts:  accounts,
-			Data:      instruction.Data,
-			ProgramID: instruction.ProgramID,
-		})
-	}
-
-	return &TransactionData{
-		Signatures:      response.Result.Transaction.Signatures,
-		Instructions:    instructions,
-		LogMessages:     response.Result.Meta.LogMessages,
-		RecentBlockhash: response.Result.Transaction.Message.RecentBlockhash,
-	}
+func uiTokenAmount(amount uint64) float64 {
+	return float64(amount) / math.Pow10(curve.TokenDecimals)
 }
@@ -4,20 +4,14 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"time"
-)
 
-const (
-	TokenDecimals           = 6
-	LamportsPerSol          = 1_000_000_000
-	VirtualTokenReservesPos = 0x08
-	VirtualSolReservesPos   = 0x10
+	"github.com/ethanhosier/pumpfun-trade-bot/curve"
 )
 
 // Structs for JSON Parsing
@@ -107,31 +101,16 @@ func decodeBase64(data string) ([]byte, error) {
 }
 
 func calculatePrice(data []byte) (float64, error) {
-	virtualTokenReserves, err := readUint64LE(data, VirtualTokenReservesPos)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read virtual token reserves: %v", err)
-	}
-
-	virtualSolReserves, err := readUint64LE(data, VirtualSolReservesPos)
+	bondingCurve, err := curve.DecodeBondingCurve(data)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read virtual SOL reserves: %v", err)
+		return 0, fmt.Errorf("failed to decode bonding curve: %v", err)
 	}
-	log.Printf("Virtual sol reserves: %d", virtualSolReserves)
-	log.Printf("Virtual token reserves: %d", virtualTokenReserves)
+	log.Printf("Virtual sol reserves: %d", bondingCurve.VirtualSolReserves)
+	log.Printf("Virtual token reserves: %d", bondingCurve.VirtualTokenReserves)
 
-	if virtualTokenReserves == 0 || virtualSolReserves == 0 {
+	if bondingCurve.VirtualTokenReserves == 0 || bondingCurve.VirtualSolReserves == 0 {
 		return 0, fmt.Errorf("invalid reserves in curve state")
 	}
 
-	// Calculate price as (VirtualSolReserves / LamportsPerSol) / (VirtualTokenReserves / 10^TokenDecimals)
-	price := float64(virtualSolReserves) / float64(virtualTokenReserves)
-	return price, nil
-}
-
-// Read uint64 value from byte array
-func readUint64LE(data []byte, offset int) (uint64, error) {
-	if len(data) < offset+8 {
-		return 0, fmt.Errorf("buffer too small to read uint64")
-	}
-	return binary.LittleEndian.Uint64(data[offset : offset+8]), nil
+	return bondingCurve.PriceInSol(), nil
 }
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/ethanhosier/pumpfun-trade-bot/blockchain"
 	"github.com/ethanhosier/pumpfun-trade-bot/botFinder"
@@ -12,6 +15,13 @@ import (
 	"github.com/ethanhosier/pumpfun-trade-bot/pumpfun"
 	"github.com/ethanhosier/pumpfun-trade-bot/storage"
 	"github.com/ethanhosier/pumpfun-trade-bot/utils"
+	"github.com/ethanhosier/pumpfun-trade-bot/walletPool"
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	walletBudgetSol     = 0.5
+	walletMaxConcurrent = 1
 )
 
 type Config struct {
@@ -23,6 +33,10 @@ type Config struct {
 	PumpFunClient       *pumpfun.PumpFunClient
 	Storage             storage.Storage
 	BotFinder           *botFinder.BotFinder
+	Signer              blockchain.Signer
+	Wallets             *walletPool.WalletPool
+	Canary              *blockchain.ProgramCanary // nil unless PUMP_CANARY_MINT is set
+	SellAmount          blockchain.SellAmount     // how much of each position the bot sells, from SELL_AMOUNT
 }
 
 func MustNewDefaultConfig() *Config {
@@ -33,10 +47,25 @@ func MustNewDefaultConfig() *Config {
 	kingOfTheHillClient := kingOfTheHill.NewKingOfTheHillClient(pumpfunClient)
 	coinInfoClient := coinInfo.NewCoinInfoClient(pumpfunClient)
 	blockchainClient := blockchain.NewBlockchainClient(heliusApiKey, coinInfoClient)
+	blockchainClient.SetEndpoints(listFrom(os.Getenv("RPC_FALLBACK_URLS")), listFrom(os.Getenv("SEND_URLS")))
+	if table := os.Getenv("PUMP_LOOKUP_TABLE"); table != "" {
+		if err := blockchainClient.SetLookupTable(solana.MustPublicKeyFromBase58(table)); err != nil {
+			panic(err)
+		}
+	}
 	clicksendClient := notifications.NewClicksendClient(utils.Required(os.Getenv("CLICKSEND_USERNAME"), "CLICKSEND_USERNAME"), utils.Required(os.Getenv("CLICKSEND_API_KEY"), "CLICKSEND_API_KEY"))
 	openaiClient := openai.NewOpenAiClient(utils.Required(os.Getenv("OPENAI_API_KEY"), "OPENAI_API_KEY"))
 	botFinder := botFinder.NewBotFinder(openaiClient, pumpfunClient, coinInfoClient, storage, kingOfTheHillClient)
 
+	signer := mustSignerFromEnv()
+	wallets := mustWalletPoolFromEnv(blockchainClient, signer)
+	canary := mustCanaryFromEnv(blockchainClient, signer)
+
+	sellAmount, err := blockchain.ParseSellAmount(os.Getenv("SELL_AMOUNT"))
+	if err != nil {
+		panic(fmt.Sprintf("invalid SELL_AMOUNT: %v", err))
+	}
+
 	return &Config{
 		HeliusApiKey:        heliusApiKey,
 		BlockchainClient:    blockchainClient,
@@ -46,5 +75,84 @@ func MustNewDefaultConfig() *Config {
 		PumpFunClient:       pumpfunClient,
 		Storage:             storage,
 		BotFinder:           botFinder,
+		Signer:              signer,
+		Wallets:             wallets,
+		Canary:              canary,
+		SellAmount:          sellAmount,
+	}
+}
+
+// mustCanaryFromEnv checks the pump program against the active curve of PUMP_CANARY_MINT, pinned
+// to the deployment at PUMP_PROGRAM_DEPLOY_SLOT if set
+func mustCanaryFromEnv(blockchainClient *blockchain.BlockchainClient, signer blockchain.Signer) *blockchain.ProgramCanary {
+	mint := os.Getenv("PUMP_CANARY_MINT")
+	if mint == "" {
+		return nil
+	}
+
+	deploySlot := uint64(0)
+	if slot := os.Getenv("PUMP_PROGRAM_DEPLOY_SLOT"); slot != "" {
+		var err error
+		if deploySlot, err = strconv.ParseUint(slot, 10, 64); err != nil {
+			panic(fmt.Sprintf("invalid PUMP_PROGRAM_DEPLOY_SLOT: %v", err))
+		}
+	}
+
+	canary, err := blockchain.NewProgramCanary(blockchainClient, signer, mint, deploySlot)
+	if err != nil {
+		panic(err)
+	}
+	return canary
+}
+
+// mustWalletPoolFromEnv pools the main wallet with any extra keypair files listed in
+// WALLET_POOL_KEYPAIR_FILES, each with the same budget and concurrency limit
+func mustWalletPoolFromEnv(blockchainClient *blockchain.BlockchainClient, signer blockchain.Signer) *walletPool.WalletPool {
+	wallets := []walletPool.WalletConfig{{Signer: signer, BudgetSol: walletBudgetSol, MaxConcurrent: walletMaxConcurrent}}
+
+	for _, path := range listFrom(os.Getenv("WALLET_POOL_KEYPAIR_FILES")) {
+		poolSigner, err := blockchain.SignerFromKeypairFile(path)
+		if err != nil {
+			panic(fmt.Sprintf("failed to load pool wallet: %v", err))
+		}
+		wallets = append(wallets, walletPool.WalletConfig{Signer: poolSigner, BudgetSol: walletBudgetSol, MaxConcurrent: walletMaxConcurrent})
+	}
+
+	pool, err := walletPool.NewWalletPool(blockchainClient, walletPool.RoundRobin, wallets...)
+	if err != nil {
+		panic(err)
+	}
+	return pool
+}
+
+// mustSignerFromEnv loads the trading wallet from an encrypted keystore, a Solana CLI keypair
+// file or a base58 private key, whichever is configured first
+func mustSignerFromEnv() blockchain.Signer {
+	var signer blockchain.Signer
+	var err error
+
+	switch {
+	case os.Getenv("WALLET_KEYSTORE") != "":
+		signer, err = blockchain.SignerFromKeystore(os.Getenv("WALLET_KEYSTORE"), utils.Required(os.Getenv("WALLET_KEYSTORE_PASSPHRASE"), "WALLET_KEYSTORE_PASSPHRASE"))
+	case os.Getenv("WALLET_KEYPAIR_FILE") != "":
+		signer, err = blockchain.SignerFromKeypairFile(os.Getenv("WALLET_KEYPAIR_FILE"))
+	default:
+		signer, err = blockchain.SignerFromEnv("WALLET_PRIVATE_KEY")
+	}
+	if err != nil {
+		panic(fmt.Sprintf("failed to load wallet: %v", err))
+	}
+
+	return signer
+}
+
+// listFrom splits an optional comma separated env var
+func listFrom(env string) []string {
+	items := []string{}
+	for _, item := range strings.Split(env, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,123 @@
+package curve
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	discriminatorSize = 8
+	bondingCurveSize  = discriminatorSize + 5*8 + 1
+	globalSize        = discriminatorSize + 1 + 2*32 + 5*8
+)
+
+var (
+	bondingCurveDiscriminator = AccountDiscriminator("BondingCurve")
+	globalDiscriminator       = AccountDiscriminator("Global")
+)
+
+// BondingCurve is the decoded pump.fun BondingCurve account
+type BondingCurve struct {
+	VirtualTokenReserves uint64
+	VirtualSolReserves   uint64
+	RealTokenReserves    uint64
+	RealSolReserves      uint64
+	TokenTotalSupply     uint64
+	Complete             bool
+}
+
+// Global is the decoded pump.fun Global account
+type Global struct {
+	Initialized                 bool
+	Authority                   solana.PublicKey
+	FeeRecipient                solana.PublicKey
+	InitialVirtualTokenReserves uint64
+	InitialVirtualSolReserves   uint64
+	InitialRealTokenReserves    uint64
+	TokenTotalSupply            uint64
+	FeeBasisPoints              uint64
+}
+
+// AccountDiscriminator is the anchor discriminator prefixed to every account of the given type
+func AccountDiscriminator(accountName string) []byte {
+	hash := sha256.Sum256([]byte("account:" + accountName))
+	return hash[:discriminatorSize]
+}
+
+func DecodeBondingCurve(data []byte) (*BondingCurve, error) {
+	if len(data) < bondingCurveSize {
+		return nil, fmt.Errorf("bonding curve account too small: %d bytes", len(data))
+	}
+	if !bytes.Equal(data[:discriminatorSize], bondingCurveDiscriminator) {
+		return nil, fmt.Errorf("account is not a bonding curve")
+	}
+
+	r := reader{data: data, offset: discriminatorSize}
+	return &BondingCurve{
+		VirtualTokenReserves: r.uint64(),
+		VirtualSolReserves:   r.uint64(),
+		RealTokenReserves:    r.uint64(),
+		RealSolReserves:      r.uint64(),
+		TokenTotalSupply:     r.uint64(),
+		Complete:             r.bool(),
+	}, nil
+}
+
+func DecodeGlobal(data []byte) (*Global, error) {
+	if len(data) < globalSize {
+		return nil, fmt.Errorf("global account too small: %d bytes", len(data))
+	}
+	if !bytes.Equal(data[:discriminatorSize], globalDiscriminator) {
+		return nil, fmt.Errorf("account is not the global account")
+	}
+
+	r := reader{data: data, offset: discriminatorSize}
+	return &Global{
+		Initialized:                 r.bool(),
+		Authority:                   r.publicKey(),
+		FeeRecipient:                r.publicKey(),
+		InitialVirtualTokenReserves: r.uint64(),
+		InitialVirtualSolReserves:   r.uint64(),
+		InitialRealTokenReserves:    r.uint64(),
+		TokenTotalSupply:            r.uint64(),
+		FeeBasisPoints:              r.uint64(),
+	}, nil
+}
+
+// Curve is the quoting state of the bonding curve at the given protocol fee
+func (b *BondingCurve) Curve(feeBasisPoints uint64) *Curve {
+	return NewCurve(b.VirtualSolReserves, b.VirtualTokenReserves, feeBasisPoints)
+}
+
+// PriceInSol is the spot price in lamports per token base unit, which is also SOL per whole token
+func (b *BondingCurve) PriceInSol() float64 {
+	return b.Curve(0).SpotPrice()
+}
+
+// reader walks a borsh encoded account; callers check the length up front
+type reader struct {
+	data   []byte
+	offset int
+}
+
+func (r *reader) uint64() uint64 {
+	v := binary.LittleEndian.Uint64(r.data[r.offset : r.offset+8])
+	r.offset += 8
+	return v
+}
+
+func (r *reader) bool() bool {
+	v := r.data[r.offset] != 0
+	r.offset++
+	return v
+}
+
+func (r *reader) publicKey() solana.PublicKey {
+	v := solana.PublicKeyFromBytes(r.data[r.offset : r.offset+32])
+	r.offset += 32
+	return v
+}
@@ -0,0 +1,216 @@
+package curve
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+const (
+	basisPoints = 10_000
+
+	TokenDecimals = 6
+
+	// DefaultFeeBasisPoints is the pump.fun protocol fee charged on both buys and sells
+	DefaultFeeBasisPoints = 100
+)
+
+// ErrNotEnoughTokens is returned when no sellable amount of tokens reaches a sol target
+var ErrNotEnoughTokens = errors.New("not enough tokens")
+
+// Curve is the constant product state of a pump.fun bonding curve
+type Curve struct {
+	VirtualSolReserves   uint64
+	VirtualTokenReserves uint64
+	FeeBasisPoints       uint64
+}
+
+// Quote is the exact outcome of a trade against a curve. SolAmount includes the protocol fee,
+// so for buys it is what leaves the wallet and for sells it is what arrives in it.
+type Quote struct {
+	SolAmount   uint64
+	TokenAmount uint64
+	Fee         uint64
+	PriceImpact float64
+}
+
+func NewCurve(virtualSolReserves uint64, virtualTokenReserves uint64, feeBasisPoints uint64) *Curve {
+	return &Curve{virtualSolReserves, virtualTokenReserves, feeBasisPoints}
+}
+
+// SpotPrice is the marginal price in lamports per token base unit
+func (c *Curve) SpotPrice() float64 {
+	if c.VirtualTokenReserves == 0 {
+		return 0
+	}
+	return float64(c.VirtualSolReserves) / float64(c.VirtualTokenReserves)
+}
+
+// BuyExactSolIn quotes how many tokens a total spend of solIn lamports (fee included) buys
+func (c *Curve) BuyExactSolIn(solIn uint64) (*Quote, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if solIn == 0 {
+		return nil, fmt.Errorf("sol amount must be greater than 0")
+	}
+
+	// strip the fee from the budget before it hits the curve
+	solIntoCurve := mulDiv(solIn, basisPoints, basisPoints+c.FeeBasisPoints)
+	tokens := mulDiv(solIntoCurve, c.VirtualTokenReserves, c.VirtualSolReserves+solIntoCurve)
+	if tokens == 0 {
+		return nil, fmt.Errorf("sol amount %d too small to buy any tokens", solIn)
+	}
+
+	return c.BuyExactTokensOut(tokens)
+}
+
+// BuyExactTokensOut quotes the total lamports (fee included) needed to buy exactly tokens
+func (c *Curve) BuyExactTokensOut(tokens uint64) (*Quote, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if tokens == 0 {
+		return nil, fmt.Errorf("token amount must be greater than 0")
+	}
+	if tokens >= c.VirtualTokenReserves {
+		return nil, fmt.Errorf("token amount %d exceeds virtual token reserves %d", tokens, c.VirtualTokenReserves)
+	}
+
+	// mirrors the program: sol_cost = amount * vSol / (vToken - amount) + 1
+	solCost := mulDiv(tokens, c.VirtualSolReserves, c.VirtualTokenReserves-tokens) + 1
+	fee := mulDiv(solCost, c.FeeBasisPoints, basisPoints)
+
+	return &Quote{
+		SolAmount:   solCost + fee,
+		TokenAmount: tokens,
+		Fee:         fee,
+		PriceImpact: float64(solCost)/float64(tokens)/c.SpotPrice() - 1,
+	}, nil
+}
+
+// SellExactTokensIn quotes the lamports (fee deducted) received for selling exactly tokens
+func (c *Curve) SellExactTokensIn(tokens uint64) (*Quote, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if tokens == 0 {
+		return nil, fmt.Errorf("token amount must be greater than 0")
+	}
+	if tokens > c.maxSellableTokens() {
+		return nil, fmt.Errorf("token amount %d overflows virtual token reserves %d", tokens, c.VirtualTokenReserves)
+	}
+
+	solOutput := mulDiv(tokens, c.VirtualSolReserves, c.VirtualTokenReserves+tokens)
+	fee := mulDiv(solOutput, c.FeeBasisPoints, basisPoints)
+
+	return &Quote{
+		SolAmount:   solOutput - fee,
+		TokenAmount: tokens,
+		Fee:         fee,
+		PriceImpact: 1 - float64(solOutput)/float64(tokens)/c.SpotPrice(),
+	}, nil
+}
+
+// SellExactSolOut quotes the fewest tokens, up to maxTokens, whose sale returns at least solOut
+// lamports after the fee
+func (c *Curve) SellExactSolOut(solOut uint64, maxTokens uint64) (*Quote, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if solOut == 0 {
+		return nil, fmt.Errorf("sol amount must be greater than 0")
+	}
+
+	// gross up for the fee, then invert sol_output = amount * vSol / (vToken + amount)
+	solFromCurve, ok := mulDivCeil(solOut, basisPoints, basisPoints-c.FeeBasisPoints)
+	if !ok || solFromCurve >= c.VirtualSolReserves {
+		return nil, fmt.Errorf("sol amount %d exceeds virtual sol reserves %d", solOut, c.VirtualSolReserves)
+	}
+	// an estimate too large for a uint64 is past any sellable amount, so the search starts at the cap
+	tokens, ok := mulDivCeil(solFromCurve, c.VirtualTokenReserves, c.VirtualSolReserves-solFromCurve)
+	if !ok {
+		tokens = math.MaxUint64
+	}
+
+	return fewestTokensSelling(tokens, min(maxTokens, c.maxSellableTokens()), solOut, c.SellExactTokensIn)
+}
+
+// maxSellableTokens is the most tokens a sell can quote before the reserves overflow
+func (c *Curve) maxSellableTokens() uint64 {
+	return math.MaxUint64 - c.VirtualTokenReserves
+}
+
+// fewestTokensSelling settles on the exact fewest tokens, up to maxTokens, whose sale returns at
+// least solOut by bisection around estimate, since rounding makes inverting a sell quote approximate
+func fewestTokensSelling(estimate uint64, maxTokens uint64, solOut uint64, sell func(tokens uint64) (*Quote, error)) (*Quote, error) {
+	reaches := func(tokens uint64) bool {
+		quote, err := sell(tokens)
+		return err == nil && quote.SolAmount >= solOut
+	}
+
+	if maxTokens == 0 || !reaches(maxTokens) {
+		return nil, fmt.Errorf("selling %d tokens returns less than %d lamports: %w", maxTokens, solOut, ErrNotEnoughTokens)
+	}
+
+	low, high := uint64(1), min(max(estimate, 1), maxTokens)
+	for !reaches(high) {
+		low = high
+		if high > maxTokens/2 {
+			high = maxTokens
+		} else {
+			high *= 2
+		}
+	}
+	for low < high {
+		mid := low + (high-low)/2
+		if reaches(mid) {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+
+	return sell(high)
+}
+
+// MaxSolCost pads a buy quote by toleranceBps for the buy instruction's max_sol_cost
+func MaxSolCost(solAmount uint64, toleranceBps uint64) uint64 {
+	return mulDiv(solAmount, basisPoints+toleranceBps, basisPoints)
+}
+
+// MinSolOutput trims a sell quote by toleranceBps for the sell instruction's min_sol_output
+func MinSolOutput(solAmount uint64, toleranceBps uint64) uint64 {
+	if toleranceBps >= basisPoints {
+		return 0
+	}
+	return mulDiv(solAmount, basisPoints-toleranceBps, basisPoints)
+}
+
+func (c *Curve) validate() error {
+	if c.VirtualSolReserves == 0 || c.VirtualTokenReserves == 0 {
+		return fmt.Errorf("invalid reserves in curve state")
+	}
+	if c.FeeBasisPoints >= basisPoints {
+		return fmt.Errorf("invalid fee of %d basis points in curve state", c.FeeBasisPoints)
+	}
+	return nil
+}
+
+// mulDiv computes a * b / d without overflowing; reserve products exceed uint64
+func mulDiv(a uint64, b uint64, d uint64) uint64 {
+	result := new(big.Int).Mul(new(big.Int).SetUint64(a), new(big.Int).SetUint64(b))
+	return result.Div(result, new(big.Int).SetUint64(d)).Uint64()
+}
+
+// mulDivCeil computes a * b / d rounded up, reporting false if the result does not fit a uint64
+func mulDivCeil(a uint64, b uint64, d uint64) (uint64, bool) {
+	result := new(big.Int).Mul(new(big.Int).SetUint64(a), new(big.Int).SetUint64(b))
+	result.Add(result, new(big.Int).SetUint64(d-1))
+	result.Div(result, new(big.Int).SetUint64(d))
+	if !result.IsUint64() {
+		return 0, false
+	}
+	return result.Uint64(), true
+}
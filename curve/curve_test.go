@@ -0,0 +1,255 @@
+package curve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// initial pump.fun curve state
+const (
+	initialVirtualSolReserves   = 30_000_000_000
+	initialVirtualTokenReserves = 1_073_000_000_000_000
+)
+
+func TestBuyExactSolIn(t *testing.T) {
+	c := NewCurve(initialVirtualSolReserves, initialVirtualTokenReserves, DefaultFeeBasisPoints)
+
+	quote, err := c.BuyExactSolIn(1_000_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 SOL less 1% fee into a 30 SOL curve buys 1.073e15 * 0.990099 / 30.990099 tokens
+	if quote.TokenAmount < 34_280_000_000_000 || quote.TokenAmount > 34_290_000_000_000 {
+		t.Errorf("Expected ~34.28M tokens, got %d", quote.TokenAmount)
+	}
+
+	if quote.SolAmount > 1_000_000_000 {
+		t.Errorf("Expected cost within budget, got %d", quote.SolAmount)
+	}
+
+	if quote.Fee == 0 || quote.PriceImpact <= 0 {
+		t.Errorf("Expected fee and positive price impact, got %+v", quote)
+	}
+}
+
+func TestBuyExactTokensOutTooLarge(t *testing.T) {
+	c := NewCurve(initialVirtualSolReserves, initialVirtualTokenReserves, DefaultFeeBasisPoints)
+
+	if _, err := c.BuyExactTokensOut(initialVirtualTokenReserves); err == nil {
+		t.Error("Expected error buying the whole curve")
+	}
+}
+
+func TestInvalidFee(t *testing.T) {
+	c := NewCurve(initialVirtualSolReserves, initialVirtualTokenReserves, 10_000)
+
+	if _, err := c.SellExactSolOut(500_000_000, math.MaxUint64); err == nil {
+		t.Error("Expected error for a fee of the whole trade")
+	}
+	if _, err := c.BuyExactSolIn(500_000_000); err == nil {
+		t.Error("Expected error for a fee of the whole trade")
+	}
+}
+
+func TestSellExactTokensInRoundTrip(t *testing.T) {
+	c := NewCurve(initialVirtualSolReserves, initialVirtualTokenReserves, DefaultFeeBasisPoints)
+
+	buy, err := c.BuyExactSolIn(1_000_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := NewCurve(c.VirtualSolReserves+buy.SolAmount-buy.Fee, c.VirtualTokenReserves-buy.TokenAmount, DefaultFeeBasisPoints)
+	sell, err := after.SellExactTokensIn(buy.TokenAmount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// selling straight back should lose roughly both fees
+	if sell.SolAmount >= buy.SolAmount || sell.SolAmount < 975_000_000 {
+		t.Errorf("Expected ~0.98 SOL back, got %d", sell.SolAmount)
+	}
+}
+
+func TestSellExactSolOut(t *testing.T) {
+	c := NewCurve(initialVirtualSolReserves+5_000_000_000, initialVirtualTokenReserves-150_000_000_000_000, DefaultFeeBasisPoints)
+
+	quote, err := c.SellExactSolOut(500_000_000, math.MaxUint64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if quote.SolAmount < 500_000_000 {
+		t.Errorf("Expected at least 500000000 lamports out, got %d", quote.SolAmount)
+	}
+
+	// one token fewer must fall short, otherwise we are overselling
+	fewer, err := c.SellExactTokensIn(quote.TokenAmount - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fewer.SolAmount >= 500_000_000 {
+		t.Errorf("Expected %d tokens to be the fewest, but %d also reach the target", quote.TokenAmount, quote.TokenAmount-1)
+	}
+
+	if _, err := c.SellExactSolOut(c.VirtualSolReserves, math.MaxUint64); err == nil {
+		t.Error("Expected error selling for the whole sol reserve")
+	}
+}
+
+func TestSellExactSolOutNearReserve(t *testing.T) {
+	c := NewCurve(initialVirtualSolReserves, initialVirtualTokenReserves, DefaultFeeBasisPoints)
+
+	// the token estimate overflows a uint64 and no sale of the supply can reach the target
+	if _, err := c.SellExactSolOut(29_699_990_000, math.MaxUint64); !errors.Is(err, ErrNotEnoughTokens) {
+		t.Errorf("Expected ErrNotEnoughTokens, got %v", err)
+	}
+
+	if _, err := c.SellExactTokensIn(math.MaxUint64); err == nil {
+		t.Error("Expected error selling more tokens than the reserves can add up to")
+	}
+}
+
+// a pool just after migration, holding the curve's SOL against the remaining supply
+const (
+	migratedPoolSolReserves   = 79_000_000_000
+	migratedPoolTokenReserves = 206_900_000_000_000
+)
+
+func TestPoolSellExactTokensIn(t *testing.T) {
+	p := NewPool(migratedPoolSolReserves, migratedPoolTokenReserves, DefaultPoolFeeNumerator, DefaultPoolFeeDenominator)
+
+	quote, err := p.SellExactTokensIn(10_000_000_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10M tokens less the 0.25% fee into a 206.9M token pool returns 79 * 9.975 / 216.875 SOL
+	if quote.SolAmount < 3_630_000_000 || quote.SolAmount > 3_640_000_000 {
+		t.Errorf("Expected ~3.633 SOL, got %d", quote.SolAmount)
+	}
+
+	if quote.Fee == 0 || quote.PriceImpact <= 0 {
+		t.Errorf("Expected fee and positive price impact, got %+v", quote)
+	}
+
+	if _, err := NewPool(migratedPoolSolReserves, migratedPoolTokenReserves, 0, 0).SellExactTokensIn(1); err == nil {
+		t.Error("Expected error for a pool without a fee denominator")
+	}
+}
+
+func TestPoolSellExactSolOut(t *testing.T) {
+	p := NewPool(migratedPoolSolReserves, migratedPoolTokenReserves, DefaultPoolFeeNumerator, DefaultPoolFeeDenominator)
+
+	quote, err := p.SellExactSolOut(500_000_000, math.MaxUint64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if quote.SolAmount < 500_000_000 {
+		t.Errorf("Expected at least 500000000 lamports out, got %d", quote.SolAmount)
+	}
+
+	fewer, err := p.SellExactTokensIn(quote.TokenAmount - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fewer.SolAmount >= 500_000_000 {
+		t.Errorf("Expected %d tokens to be the fewest, but %d also reach the target", quote.TokenAmount, quote.TokenAmount-1)
+	}
+
+	if _, err := p.SellExactSolOut(migratedPoolSolReserves, math.MaxUint64); err == nil {
+		t.Error("Expected error selling for the whole sol reserve")
+	}
+
+	// a target the held tokens cannot reach, even though more tokens would
+	if _, err := p.SellExactSolOut(500_000_000, quote.TokenAmount-1); !errors.Is(err, ErrNotEnoughTokens) {
+		t.Errorf("Expected ErrNotEnoughTokens selling with too few tokens, got %v", err)
+	}
+}
+
+func TestPoolSellExactSolOutNearReserve(t *testing.T) {
+	p := NewPool(100_000_000_000, 200_000_000_000_000, DefaultPoolFeeNumerator, DefaultPoolFeeDenominator)
+
+	if _, err := p.SellExactSolOut(99_999_000_000, math.MaxUint64); !errors.Is(err, ErrNotEnoughTokens) {
+		t.Errorf("Expected ErrNotEnoughTokens, got %v", err)
+	}
+
+	if _, err := p.SellExactTokensIn(math.MaxUint64); err == nil {
+		t.Error("Expected error selling more tokens than the reserves can add up to")
+	}
+}
+
+func TestSlippageBounds(t *testing.T) {
+	if got := MaxSolCost(1_000, 500); got != 1_050 {
+		t.Errorf("Expected 1050, got %d", got)
+	}
+
+	if got := MinSolOutput(1_000, 500); got != 950 {
+		t.Errorf("Expected 950, got %d", got)
+	}
+
+	if got := MinSolOutput(1_000, 10_000); got != 0 {
+		t.Errorf("Expected 0, got %d", got)
+	}
+}
+
+func TestAccountDiscriminator(t *testing.T) {
+	expected := []byte{23, 183, 248, 55, 96, 216, 172, 96}
+	if !bytes.Equal(AccountDiscriminator("BondingCurve"), expected) {
+		t.Errorf("Expected %v, got %v", expected, AccountDiscriminator("BondingCurve"))
+	}
+}
+
+func TestDecodeBondingCurve(t *testing.T) {
+	data := append([]byte{}, bondingCurveDiscriminator...)
+	for _, v := range []uint64{initialVirtualTokenReserves, initialVirtualSolReserves, 793_100_000_000_000, 0, 1_000_000_000_000_000} {
+		data = binary.LittleEndian.AppendUint64(data, v)
+	}
+	data = append(data, 1)
+
+	bondingCurve, err := DecodeBondingCurve(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bondingCurve.VirtualSolReserves != initialVirtualSolReserves || bondingCurve.RealTokenReserves != 793_100_000_000_000 || !bondingCurve.Complete {
+		t.Errorf("Unexpected bonding curve: %+v", bondingCurve)
+	}
+
+	if _, err := DecodeBondingCurve(data[:20]); err == nil {
+		t.Error("Expected error decoding truncated account")
+	}
+
+	data[0]++
+	if _, err := DecodeBondingCurve(data); err == nil {
+		t.Error("Expected error decoding wrong discriminator")
+	}
+}
+
+func TestDecodeGlobal(t *testing.T) {
+	feeRecipient := solana.MustPublicKeyFromBase58("CebN5WGQ4jvEPvsVU4EoHEpgzq1VV7AbicfhtW4xC9iM")
+
+	data := append([]byte{}, globalDiscriminator...)
+	data = append(data, 1)
+	data = append(data, make([]byte, 32)...)
+	data = append(data, feeRecipient.Bytes()...)
+	for _, v := range []uint64{initialVirtualTokenReserves, initialVirtualSolReserves, 793_100_000_000_000, 1_000_000_000_000_000, 100} {
+		data = binary.LittleEndian.AppendUint64(data, v)
+	}
+
+	global, err := DecodeGlobal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !global.FeeRecipient.Equals(feeRecipient) || global.FeeBasisPoints != 100 || !global.Initialized {
+		t.Errorf("Unexpected global: %+v", global)
+	}
+}
@@ -0,0 +1,101 @@
+package curve
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// the Raydium AMM v4 swap fee graduated pump.fun coins trade at
+	DefaultPoolFeeNumerator   = 25
+	DefaultPoolFeeDenominator = 10_000
+)
+
+// Pool is the constant product state of the AMM pool a graduated coin migrates to. Unlike the
+// bonding curve, reserves are real and the fee is taken from the tokens going in.
+type Pool struct {
+	SolReserves    uint64
+	TokenReserves  uint64
+	FeeNumerator   uint64
+	FeeDenominator uint64
+}
+
+func NewPool(solReserves uint64, tokenReserves uint64, feeNumerator uint64, feeDenominator uint64) *Pool {
+	return &Pool{solReserves, tokenReserves, feeNumerator, feeDenominator}
+}
+
+// SpotPrice is the marginal price in lamports per token base unit
+func (p *Pool) SpotPrice() float64 {
+	if p.TokenReserves == 0 {
+		return 0
+	}
+	return float64(p.SolReserves) / float64(p.TokenReserves)
+}
+
+// SellExactTokensIn quotes the lamports received for selling exactly tokens. Fee is the lamports
+// the fee withheld from the tokens would have fetched.
+func (p *Pool) SellExactTokensIn(tokens uint64) (*Quote, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if tokens == 0 {
+		return nil, fmt.Errorf("token amount must be greater than 0")
+	}
+	if tokens > p.maxSellableTokens() {
+		return nil, fmt.Errorf("token amount %d overflows token reserves %d", tokens, p.TokenReserves)
+	}
+
+	// mirrors the program: the fee rounds up, the output rounds down
+	fee, _ := mulDivCeil(tokens, p.FeeNumerator, p.FeeDenominator) // never more than tokens
+	tokensIntoPool := tokens - fee
+	solOutput := mulDiv(tokensIntoPool, p.SolReserves, p.TokenReserves+tokensIntoPool)
+	if solOutput == 0 {
+		return nil, fmt.Errorf("token amount %d too small to receive any sol", tokens)
+	}
+	solWithoutFee := mulDiv(tokens, p.SolReserves, p.TokenReserves+tokens)
+
+	return &Quote{
+		SolAmount:   solOutput,
+		TokenAmount: tokens,
+		Fee:         solWithoutFee - solOutput,
+		PriceImpact: 1 - float64(solWithoutFee)/float64(tokens)/p.SpotPrice(),
+	}, nil
+}
+
+// SellExactSolOut quotes the fewest tokens, up to maxTokens, whose sale returns at least solOut
+// lamports after the fee
+func (p *Pool) SellExactSolOut(solOut uint64, maxTokens uint64) (*Quote, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if solOut == 0 {
+		return nil, fmt.Errorf("sol amount must be greater than 0")
+	}
+	if solOut >= p.SolReserves {
+		return nil, fmt.Errorf("sol amount %d exceeds sol reserves %d", solOut, p.SolReserves)
+	}
+
+	// invert sol_output = amount * sol / (token + amount), then gross up for the fee
+	tokensIntoPool, ok := mulDivCeil(solOut, p.TokenReserves, p.SolReserves-solOut)
+	tokens, grossed := mulDivCeil(tokensIntoPool, p.FeeDenominator, p.FeeDenominator-p.FeeNumerator)
+	if !ok || !grossed {
+		tokens = math.MaxUint64
+	}
+
+	return fewestTokensSelling(tokens, min(maxTokens, p.maxSellableTokens()), solOut, p.SellExactTokensIn)
+}
+
+// maxSellableTokens is the most tokens a sell can quote before the reserves overflow
+func (p *Pool) maxSellableTokens() uint64 {
+	return math.MaxUint64 - p.TokenReserves
+}
+
+func (p *Pool) validate() error {
+	if p.SolReserves == 0 || p.TokenReserves == 0 {
+		return fmt.Errorf("invalid reserves in pool state")
+	}
+	if p.FeeDenominator == 0 || p.FeeNumerator >= p.FeeDenominator {
+		return fmt.Errorf("invalid fee %d/%d in pool state", p.FeeNumerator, p.FeeDenominator)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package idl
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const discriminatorSize = 8
+
+// IDL is the part of an Anchor IDL needed to build instructions. Both the legacy layout (isMut,
+// isSigner, publicKey) and the 0.30 layout (writable, signer, pubkey, fixed addresses) are read.
+type IDL struct {
+	Address      string        `json:"address"`
+	Name         string        `json:"name"`
+	Version      string        `json:"version"`
+	Instructions []Instruction `json:"instructions"`
+	Metadata     struct {
+		Address string `json:"address"`
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"metadata"`
+}
+
+type Instruction struct {
+	Name     string    `json:"name"`
+	Accounts []Account `json:"accounts"`
+	Args     []Field   `json:"args"`
+
+	// explicit in 0.30 IDLs, otherwise derived from the name
+	RawDiscriminator discriminator `json:"discriminator"`
+}
+
+type Account struct {
+	Name     string `json:"name"`
+	IsMut    bool   `json:"isMut"`
+	IsSigner bool   `json:"isSigner"`
+	Writable bool   `json:"writable"`
+	Signer   bool   `json:"signer"`
+	Address  string `json:"address"` // set for accounts the program pins, such as its own ID
+}
+
+// Field is an instruction argument. Only primitive types can be encoded; others are kept so an IDL
+// carrying instructions we never build still loads.
+type Field struct {
+	Name string          `json:"name"`
+	Type json.RawMessage `json:"type"`
+}
+
+// discriminator unmarshals from the JSON array of numbers IDLs use rather than base64
+type discriminator []byte
+
+func (d *discriminator) UnmarshalJSON(data []byte) error {
+	var values []uint8
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*d = discriminator(values)
+	return nil
+}
+
+func Parse(data []byte) (*IDL, error) {
+	idl := &IDL{}
+	if err := json.Unmarshal(data, idl); err != nil {
+		return nil, fmt.Errorf("failed to parse IDL: %w", err)
+	}
+
+	for _, instruction := range idl.Instructions {
+		if len(instruction.RawDiscriminator) != 0 && len(instruction.RawDiscriminator) != discriminatorSize {
+			return nil, fmt.Errorf("instruction %s has a %d byte discriminator", instruction.Name, len(instruction.RawDiscriminator))
+		}
+	}
+
+	return idl, nil
+}
+
+func MustParse(data []byte) *IDL {
+	idl, err := Parse(data)
+	if err != nil {
+		panic(err)
+	}
+	return idl
+}
+
+// ProgramID is the program the IDL describes, from the top level address or legacy metadata
+func (i *IDL) ProgramID() (solana.PublicKey, error) {
+	address := i.Address
+	if address == "" {
+		address = i.Metadata.Address
+	}
+	if address == "" {
+		return solana.PublicKey{}, fmt.Errorf("IDL has no program address")
+	}
+	return solana.PublicKeyFromBase58(address)
+}
+
+func (i *IDL) Instruction(name string) (*Instruction, error) {
+	for j := range i.Instructions {
+		if i.Instructions[j].Name == name {
+			return &i.Instructions[j], nil
+		}
+	}
+	return nil, fmt.Errorf("IDL has no %s instruction", name)
+}
+
+// Build encodes the named instruction against the IDL's program
+func (i *IDL) Build(name string, accounts map[string]solana.PublicKey, args map[string]interface{}) (*solana.GenericInstruction, error) {
+	programID, err := i.ProgramID()
+	if err != nil {
+		return nil, err
+	}
+
+	instruction, err := i.Instruction(name)
+	if err != nil {
+		return nil, err
+	}
+
+	metas, err := instruction.AccountMetas(accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := instruction.Data(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return solana.NewInstruction(programID, metas, data), nil
+}
+
+func (in *Instruction) Discriminator() []byte {
+	if len(in.RawDiscriminator) == discriminatorSize {
+		return in.RawDiscriminator
+	}
+	return InstructionDiscriminator(in.Name)
+}
+
+// AccountIndex is the position of the named account, matched in camel or snake case
+func (in *Instruction) AccountIndex(name string) (int, bool) {
+	for i, account := range in.Accounts {
+		if snakeCase(account.Name) == snakeCase(name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// AccountMetas lists the instruction's accounts in IDL order. Names match in camel or snake case
+// alike, since 0.30 IDLs renamed every account to snake case. Accounts with a fixed address in the
+// IDL may be left out of the map.
+func (in *Instruction) AccountMetas(accounts map[string]solana.PublicKey) ([]*solana.AccountMeta, error) {
+	named := make(map[string]solana.PublicKey, len(accounts))
+	for name, key := range accounts {
+		named[snakeCase(name)] = key
+	}
+
+	metas := make([]*solana.AccountMeta, 0, len(in.Accounts))
+	for _, account := range in.Accounts {
+		key, ok := named[snakeCase(account.Name)]
+		if !ok && account.Address != "" {
+			var err error
+			if key, err = solana.PublicKeyFromBase58(account.Address); err != nil {
+				return nil, fmt.Errorf("invalid address for %s account %s: %w", in.Name, account.Name, err)
+			}
+			ok = true
+		}
+		if !ok {
+			return nil, fmt.Errorf("missing %s account %s", in.Name, account.Name)
+		}
+
+		metas = append(metas, solana.NewAccountMeta(key, account.IsMut || account.Writable, account.IsSigner || account.Signer))
+	}
+	return metas, nil
+}
+
+// Data is the discriminator followed by the Borsh encoded args in IDL order
+func (in *Instruction) Data(args map[string]interface{}) ([]byte, error) {
+	if len(args) != len(in.Args) {
+		return nil, fmt.Errorf("%s takes %d args, got %d", in.Name, len(in.Args), len(args))
+	}
+
+	data := append([]byte{}, in.Discriminator()...)
+	for _, field := range in.Args {
+		value, ok := args[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing %s arg %s", in.Name, field.Name)
+		}
+
+		var err error
+		if data, err = appendArg(data, field, value); err != nil {
+			return nil, fmt.Errorf("invalid %s arg %s: %w", in.Name, field.Name, err)
+		}
+	}
+	return data, nil
+}
+
+func appendArg(data []byte, field Field, value interface{}) ([]byte, error) {
+	var typeName string
+	if err := json.Unmarshal(field.Type, &typeName); err != nil {
+		return nil, fmt.Errorf("unsupported type %s", field.Type)
+	}
+
+	mismatch := fmt.Errorf("expected a %s, got %T", typeName, value)
+	switch typeName {
+	case "bool":
+		v, ok := value.(bool)
+		if !ok {
+			return nil, mismatch
+		}
+		if v {
+			return append(data, 1), nil
+		}
+		return append(data, 0), nil
+	case "u8":
+		v, ok := value.(uint8)
+		if !ok {
+			return nil, mismatch
+		}
+		return append(data, v), nil
+	case "u16":
+		v, ok := value.(uint16)
+		if !ok {
+			return nil, mismatch
+		}
+		return binary.LittleEndian.AppendUint16(data, v), nil
+	case "u32":
+		v, ok := value.(uint32)
+		if !ok {
+			return nil, mismatch
+		}
+		return binary.LittleEndian.AppendUint32(data, v), nil
+	case "u64":
+		v, ok := value.(uint64)
+		if !ok {
+			return nil, mismatch
+		}
+		return binary.LittleEndian.AppendUint64(data, v), nil
+	case "i64":
+		v, ok := value.(int64)
+		if !ok {
+			return nil, mismatch
+		}
+		return binary.LittleEndian.AppendUint64(data, uint64(v)), nil
+	case "string":
+		v, ok := value.(string)
+		if !ok {
+			return nil, mismatch
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(v)))
+		return append(data, v...), nil
+	case "publicKey", "pubkey":
+		v, ok := value.(solana.PublicKey)
+		if !ok {
+			return nil, mismatch
+		}
+		return append(data, v.Bytes()...), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typeName)
+	}
+}
+
+// InstructionDiscriminator is the anchor discriminator of an instruction. Legacy IDLs name
+// instructions in camel case, but anchor hashes the snake case method name.
+func InstructionDiscriminator(instructionName string) []byte {
+	hash := sha256.Sum256([]byte("global:" + snakeCase(instructionName)))
+	return hash[:discriminatorSize]
+}
+
+func snakeCase(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
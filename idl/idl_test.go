@@ -0,0 +1,150 @@
+package idl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const legacyIDL = `{
+  "version": "0.1.0",
+  "name": "example",
+  "instructions": [
+    {
+      "name": "setParams",
+      "accounts": [
+        { "name": "global", "isMut": true, "isSigner": false },
+        { "name": "user", "isMut": true, "isSigner": true }
+      ],
+      "args": [
+        { "name": "feeRecipient", "type": "publicKey" },
+        { "name": "feeBasisPoints", "type": "u64" },
+        { "name": "label", "type": "string" },
+        { "name": "enabled", "type": "bool" }
+      ]
+    },
+    {
+      "name": "configure",
+      "accounts": [],
+      "args": [{ "name": "params", "type": { "defined": "Params" } }]
+    }
+  ],
+  "metadata": { "address": "6EF8rrecthR5Dkzon8Nwu78hRvfCKubJ14M5uBEwF6P" }
+}`
+
+const anchor030IDL = `{
+  "address": "6EF8rrecthR5Dkzon8Nwu78hRvfCKubJ14M5uBEwF6P",
+  "metadata": { "name": "example", "version": "0.1.0" },
+  "instructions": [
+    {
+      "name": "buy",
+      "discriminator": [1, 2, 3, 4, 5, 6, 7, 8],
+      "accounts": [
+        { "name": "user", "writable": true, "signer": true },
+        { "name": "bonding_curve", "writable": true },
+        { "name": "system_program", "address": "11111111111111111111111111111111" }
+      ],
+      "args": [{ "name": "amount", "type": "u64" }]
+    }
+  ]
+}`
+
+func TestInstructionDiscriminator(t *testing.T) {
+	// the u64 pump.fun's buy discriminator was hard-coded as
+	if got := binary.LittleEndian.Uint64(InstructionDiscriminator("buy")); got != 16927863322537952870 {
+		t.Errorf("Expected the buy discriminator, got %d", got)
+	}
+
+	if !bytes.Equal(InstructionDiscriminator("setParams"), InstructionDiscriminator("set_params")) {
+		t.Errorf("Expected camel case names hashed as snake case")
+	}
+}
+
+func TestBuildLegacyInstruction(t *testing.T) {
+	idl := MustParse([]byte(legacyIDL))
+	global, user, feeRecipient := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	instruction, err := idl.Build("setParams", map[string]solana.PublicKey{"global": global, "user": user}, map[string]interface{}{
+		"feeRecipient":   feeRecipient,
+		"feeBasisPoints": uint64(100),
+		"label":          "abc",
+		"enabled":        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !instruction.ProgramID().Equals(solana.MustPublicKeyFromBase58("6EF8rrecthR5Dkzon8Nwu78hRvfCKubJ14M5uBEwF6P")) {
+		t.Errorf("Expected the metadata address as program, got %s", instruction.ProgramID())
+	}
+
+	accounts := instruction.Accounts()
+	if len(accounts) != 2 || !accounts[0].PublicKey.Equals(global) || accounts[0].IsSigner || !accounts[1].IsSigner || !accounts[1].IsWritable {
+		t.Errorf("Expected global then the signing user, got %v", accounts)
+	}
+
+	data, _ := instruction.Data()
+	expected := append([]byte{}, InstructionDiscriminator("set_params")...)
+	expected = append(expected, feeRecipient.Bytes()...)
+	expected = binary.LittleEndian.AppendUint64(expected, 100)
+	expected = append(binary.LittleEndian.AppendUint32(expected, 3), "abc"...)
+	expected = append(expected, 1)
+	if !bytes.Equal(data, expected) {
+		t.Errorf("Expected data %x, got %x", expected, data)
+	}
+}
+
+func TestBuildAnchor030Instruction(t *testing.T) {
+	idl := MustParse([]byte(anchor030IDL))
+	user := solana.NewWallet().PublicKey()
+
+	bondingCurve := solana.NewWallet().PublicKey()
+
+	// camel case names, as callers written against legacy IDLs pass them
+	instruction, err := idl.Build("buy", map[string]solana.PublicKey{"user": user, "bondingCurve": bondingCurve}, map[string]interface{}{"amount": uint64(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := instruction.Accounts()
+	if len(accounts) != 3 || !accounts[0].IsWritable || !accounts[0].IsSigner || !accounts[1].PublicKey.Equals(bondingCurve) || !accounts[2].PublicKey.Equals(solana.SystemProgramID) {
+		t.Errorf("Expected the user, the bonding curve then the pinned system program, got %v", accounts)
+	}
+
+	data, _ := instruction.Data()
+	if !bytes.Equal(data, []byte{1, 2, 3, 4, 5, 6, 7, 8, 7, 0, 0, 0, 0, 0, 0, 0}) {
+		t.Errorf("Expected the explicit discriminator then the amount, got %x", data)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	idl := MustParse([]byte(legacyIDL))
+	global, user := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	args := func() map[string]interface{} {
+		return map[string]interface{}{"feeRecipient": user, "feeBasisPoints": uint64(1), "label": "", "enabled": false}
+	}
+
+	if _, err := idl.Build("missing", nil, nil); err == nil {
+		t.Errorf("Expected an error for an unknown instruction")
+	}
+	if _, err := idl.Build("setParams", map[string]solana.PublicKey{"global": global}, args()); err == nil {
+		t.Errorf("Expected an error for a missing account")
+	}
+
+	wrongType := args()
+	wrongType["feeBasisPoints"] = 1
+	if _, err := idl.Build("setParams", map[string]solana.PublicKey{"global": global, "user": user}, wrongType); err == nil {
+		t.Errorf("Expected an error for an int passed as u64")
+	}
+
+	// instructions with types we cannot encode still load, they just cannot be built
+	if _, err := idl.Build("configure", nil, map[string]interface{}{"params": nil}); err == nil {
+		t.Errorf("Expected an error for a defined type")
+	}
+
+	if _, err := Parse([]byte(`{"instructions": [{"name": "buy", "discriminator": [1, 2]}]}`)); err == nil {
+		t.Errorf("Expected an error for a short discriminator")
+	}
+}
@@ -0,0 +1,237 @@
+package latency
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// DurationBuckets are the upper bounds, in milliseconds, stage durations are counted into
+	DurationBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1_000, 2_500, 5_000, 10_000, 30_000}
+	// SlotBuckets are the upper bounds slot gaps are counted into
+	SlotBuckets = []float64{0, 1, 2, 3, 4, 5, 10, 20, 50}
+)
+
+// Histogram counts observations into fixed buckets, with an overflow bucket past the last bound
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64 // one per bound, then the overflow
+	count  uint64
+	sum    float64
+	min    float64
+	max    float64
+}
+
+func NewHistogram(bounds ...float64) *Histogram {
+	sorted := append([]float64{}, bounds...)
+	sort.Float64s(sorted)
+	return &Histogram{bounds: sorted, counts: make([]uint64, len(sorted)+1), min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.counts[sort.SearchFloat64s(h.bounds, value)]++
+	h.count++
+	h.sum += value
+	h.min = min(h.min, value)
+	h.max = max(h.max, value)
+}
+
+type Bucket struct {
+	UpperBound float64 // +Inf for the overflow bucket
+	Count      uint64
+}
+
+// MarshalJSON writes the overflow bound as "+Inf", which JSON numbers cannot hold
+func (b Bucket) MarshalJSON() ([]byte, error) {
+	var upperBound interface{} = b.UpperBound
+	if math.IsInf(b.UpperBound, 1) {
+		upperBound = "+Inf"
+	}
+	return json.Marshal(struct {
+		UpperBound interface{} `json:"le"`
+		Count      uint64      `json:"count"`
+	}{upperBound, b.Count})
+}
+
+// Snapshot is a histogram's state at a point in time. Quantiles are the upper bound of the bucket
+// they fall in, or the largest observation for the overflow bucket.
+type Snapshot struct {
+	Count   uint64   `json:"count"`
+	Sum     float64  `json:"sum"`
+	Min     float64  `json:"min"`
+	Max     float64  `json:"max"`
+	Mean    float64  `json:"mean"`
+	P50     float64  `json:"p50"`
+	P90     float64  `json:"p90"`
+	P99     float64  `json:"p99"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+func (h *Histogram) Snapshot() Snapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := Snapshot{Count: h.count, Sum: h.sum, Buckets: make([]Bucket, len(h.counts))}
+	for i, count := range h.counts {
+		snapshot.Buckets[i] = Bucket{UpperBound: math.Inf(1), Count: count}
+		if i < len(h.bounds) {
+			snapshot.Buckets[i].UpperBound = h.bounds[i]
+		}
+	}
+	if h.count == 0 {
+		return snapshot
+	}
+
+	snapshot.Min, snapshot.Max, snapshot.Mean = h.min, h.max, h.sum/float64(h.count)
+	snapshot.P50, snapshot.P90, snapshot.P99 = h.quantile(0.5), h.quantile(0.9), h.quantile(0.99)
+	return snapshot
+}
+
+func (h *Histogram) quantile(q float64) float64 {
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	seen := uint64(0)
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			if i < len(h.bounds) {
+				return min(h.bounds[i], h.max)
+			}
+			break
+		}
+	}
+	return h.max
+}
+
+// Recorder keeps a histogram per named stage, durations in milliseconds and slot gaps in slots
+type Recorder struct {
+	mu         sync.Mutex
+	histograms map[string]*Histogram
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{histograms: map[string]*Histogram{}}
+}
+
+func (r *Recorder) histogram(name string, bounds []float64) *Histogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.histograms[name]
+	if !ok {
+		h = NewHistogram(bounds...)
+		r.histograms[name] = h
+	}
+	return h
+}
+
+func (r *Recorder) ObserveDuration(name string, d time.Duration) {
+	r.histogram(name, DurationBuckets).Observe(float64(d) / float64(time.Millisecond))
+}
+
+func (r *Recorder) ObserveSlots(name string, slots int64) {
+	r.histogram(name, SlotBuckets).Observe(float64(slots))
+}
+
+func (r *Recorder) Snapshot() map[string]Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshots := make(map[string]Snapshot, len(r.histograms))
+	for name, h := range r.histograms {
+		snapshots[name] = h.Snapshot()
+	}
+	return snapshots
+}
+
+// WriteJSON exports every histogram, keyed by name
+func (r *Recorder) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.Snapshot())
+}
+
+// WriteJSONFile exports every histogram to path, replacing it whole so readers never see half a file
+func (r *Recorder) WriteJSONFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".latency-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create latency export: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := r.WriteJSON(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write latency export: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write latency export: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// String summarises each histogram on a line, sorted by name
+func (r *Recorder) String() string {
+	snapshots := r.Snapshot()
+	names := make([]string, 0, len(snapshots))
+	for name := range snapshots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		s := snapshots[name]
+		sb.WriteString(fmt.Sprintf("%-24s n=%-5d mean=%-9.1f p50=%-8.1f p90=%-8.1f p99=%-8.1f max=%.1f\n", name, s.Count, s.Mean, s.P50, s.P90, s.P99, s.Max))
+	}
+	return sb.String()
+}
+
+// Trace timestamps the stages of one run through a pipeline. Each mark records the time since the
+// previous mark, so the stage histograms add up to where the total went.
+type Trace struct {
+	recorder *Recorder
+	start    time.Time
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (r *Recorder) StartTrace(start time.Time) *Trace {
+	return &Trace{recorder: r, start: start, last: start}
+}
+
+// Mark records the time since the previous mark as the named stage
+func (t *Trace) Mark(stage string) {
+	t.MarkAt(stage, time.Now())
+}
+
+// MarkAt records a stage that finished at a known time, such as one timed inside a client call
+func (t *Trace) MarkAt(stage string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.recorder.ObserveDuration(stage, at.Sub(t.last))
+	t.last = at
+}
+
+// Since records the time since the trace started, for stages running alongside the marked ones
+// and for the total
+func (t *Trace) Since(stage string) {
+	t.SinceAt(stage, time.Now())
+}
+
+func (t *Trace) SinceAt(stage string, at time.Time) {
+	t.recorder.ObserveDuration(stage, at.Sub(t.start))
+}
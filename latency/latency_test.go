@@ -0,0 +1,118 @@
+package latency
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	h := NewHistogram(10, 1, 5)
+	for _, value := range []float64{0.5, 1, 3, 7, 20} {
+		h.Observe(value)
+	}
+
+	s := h.Snapshot()
+	if s.Count != 5 || s.Min != 0.5 || s.Max != 20 {
+		t.Errorf("Expected 5 observations from 0.5 to 20, got %+v", s)
+	}
+
+	// an observation on a bound counts into that bound's bucket
+	expected := []uint64{2, 1, 1, 1}
+	for i, count := range expected {
+		if s.Buckets[i].Count != count {
+			t.Errorf("Expected %d in bucket %d, got %d", count, i, s.Buckets[i].Count)
+		}
+	}
+	if s.Buckets[0].UpperBound != 1 || s.Buckets[3].UpperBound <= 10 {
+		t.Errorf("Expected sorted bounds ending in +Inf, got %+v", s.Buckets)
+	}
+}
+
+func TestHistogramQuantiles(t *testing.T) {
+	h := NewHistogram(DurationBuckets...)
+	for i := 0; i < 98; i++ {
+		h.Observe(20)
+	}
+	h.Observe(400)
+	h.Observe(45_000)
+
+	s := h.Snapshot()
+	if s.P50 != 25 {
+		t.Errorf("Expected p50 at the 25ms bound, got %f", s.P50)
+	}
+	if s.P99 != 500 {
+		t.Errorf("Expected p99 at the 500ms bound, got %f", s.P99)
+	}
+	if s.Max != 45_000 {
+		t.Errorf("Expected max 45000, got %f", s.Max)
+	}
+
+	// a quantile in a bucket never reaches past the largest observation
+	small := NewHistogram(DurationBuckets...)
+	small.Observe(7)
+	if p50 := small.Snapshot().P50; p50 != 7 {
+		t.Errorf("Expected p50 capped at 7, got %f", p50)
+	}
+
+	empty := NewHistogram(DurationBuckets...).Snapshot()
+	if empty.Count != 0 || empty.P99 != 0 || empty.Min != 0 {
+		t.Errorf("Expected an empty snapshot, got %+v", empty)
+	}
+}
+
+func TestRecorderJSON(t *testing.T) {
+	r := NewRecorder()
+	r.ObserveDuration("buy confirmed", 1500*time.Millisecond)
+	r.ObserveSlots("slot gap", 100)
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"le": "+Inf"`) {
+		t.Errorf("Expected the overflow bucket bound as +Inf, got %s", buf.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "latency.json")
+	if err := r.WriteJSONFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exported := map[string]struct {
+		Count uint64  `json:"count"`
+		Max   float64 `json:"max"`
+	}{}
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatal(err)
+	}
+	if exported["buy confirmed"].Max != 1500 || exported["slot gap"].Count != 1 {
+		t.Errorf("Expected both histograms exported, got %+v", exported)
+	}
+}
+
+func TestTraceMarks(t *testing.T) {
+	r := NewRecorder()
+	start := time.Now()
+	trace := r.StartTrace(start)
+
+	trace.MarkAt("fetched", start.Add(30*time.Millisecond))
+	trace.MarkAt("sent", start.Add(100*time.Millisecond))
+	trace.SinceAt("total", start.Add(100*time.Millisecond))
+
+	snapshots := r.Snapshot()
+	if snapshots["fetched"].Sum != 30 || snapshots["sent"].Sum != 70 {
+		t.Errorf("Expected each mark timed from the previous one, got %+v", snapshots)
+	}
+	if snapshots["total"].Sum != 100 {
+		t.Errorf("Expected total timed from the start, got %f", snapshots["total"].Sum)
+	}
+}
@@ -2,16 +2,26 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
 	"time"
 
+	"github.com/ethanhosier/pumpfun-trade-bot/blockchain"
 	"github.com/ethanhosier/pumpfun-trade-bot/config"
 	"github.com/ethanhosier/pumpfun-trade-bot/pumpSnipeBot"
+	"github.com/gagliardetto/solana-go"
 	"github.com/joho/godotenv"
 )
 
 func main() {
 	// Add botFinder flag
 	botFinderEnabled := flag.Bool("botFinder", false, "Enable bot finder functionality")
+	showPortfolio := flag.Bool("portfolio", false, "Print the trading wallet's holdings and exit")
+	writeKeystore := flag.String("writeKeystore", "", "Encrypt WALLET_PRIVATE_KEY under WALLET_KEYSTORE_PASSPHRASE into this keystore file and exit")
+	fundWallets := flag.Float64("fundWallets", 0, "Send this much SOL from the main wallet to every pool wallet and exit")
+	sweepWallets := flag.Bool("sweepWallets", false, "Sweep idle pool wallets back to the main wallet and exit")
+	createLookupTable := flag.Bool("createLookupTable", false, "Create an address lookup table of the pump.fun accounts for PUMP_LOOKUP_TABLE and exit")
+	latencyFile := flag.String("latencyFile", "", "Write the snipe pipeline latency histograms to this JSON file after every buy")
 	flag.Parse()
 
 	err := godotenv.Load()
@@ -19,8 +29,53 @@ func main() {
 		panic(err)
 	}
 
+	if *writeKeystore != "" {
+		key := solana.MustPrivateKeyFromBase58(os.Getenv("WALLET_PRIVATE_KEY"))
+		if err := blockchain.WriteKeystore(*writeKeystore, key, os.Getenv("WALLET_KEYSTORE_PASSPHRASE")); err != nil {
+			panic(err)
+		}
+		fmt.Printf("Wrote keystore for %s to %s\n", key.PublicKey(), *writeKeystore)
+		return
+	}
+
 	config := config.MustNewDefaultConfig()
 
+	if *showPortfolio {
+		portfolio, err := config.BlockchainClient.Portfolio(config.Signer.PublicKey().String())
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(portfolio)
+		return
+	}
+
+	if *fundWallets > 0 {
+		signatures, err := config.Wallets.Fund(config.Signer, *fundWallets)
+		fmt.Println(signatures)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if *sweepWallets {
+		signatures, err := config.Wallets.Sweep(config.Signer.PublicKey().String())
+		fmt.Println(signatures)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if *createLookupTable {
+		table, err := config.BlockchainClient.CreateLookupTable(config.Signer, blockchain.PumpLookupTableAddresses())
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Created lookup table %s, set PUMP_LOOKUP_TABLE to use it\n", table)
+		return
+	}
+
 	// Only run bot finder if flag is set
 	if *botFinderEnabled {
 		go func() { config.KingOfTheHillClient.Start(5*time.Second, 999999) }()
@@ -28,7 +83,12 @@ func main() {
 	}
 
 	// Buy Bot
-	pumpSnipeBot := pumpSnipeBot.NewPumpSnipeBot(config.Notifier, config.BlockchainClient, config.CoinInfoClient, config.PumpFunClient)
+	pumpSnipeBot := pumpSnipeBot.NewPumpSnipeBot(config.Notifier, config.BlockchainClient, config.CoinInfoClient, config.PumpFunClient, config.Wallets)
+	pumpSnipeBot.SetLatencyExportPath(*latencyFile)
+	pumpSnipeBot.SetSellAmount(config.SellAmount)
+	if config.Canary != nil {
+		pumpSnipeBot.SetCanary(config.Canary)
+	}
 	wallets := []string{"J4bzyKJKZKKz2HUGFiq3DMRaxEaw6MxKf8rjGTvpkqaU"}
 	panic(pumpSnipeBot.Start(wallets))
 }
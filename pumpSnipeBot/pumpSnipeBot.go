@@ -1,30 +1,36 @@
 package pumpSnipeBot
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethanhosier/pumpfun-trade-bot/blockchain"
 	"github.com/ethanhosier/pumpfun-trade-bot/coinInfo"
+	"github.com/ethanhosier/pumpfun-trade-bot/latency"
 	"github.com/ethanhosier/pumpfun-trade-bot/notifications"
 	"github.com/ethanhosier/pumpfun-trade-bot/pumpfun"
+	"github.com/ethanhosier/pumpfun-trade-bot/utils"
+	"github.com/ethanhosier/pumpfun-trade-bot/walletPool"
 )
 
 const (
-	buyAmountSol = 0.05
-	buySlippage  = 0.9
+	buyAmountSol   = 0.05
+	buySlippageBps = 1500
 
 	ethanPhoneNumber = "+447476133726"
 	maxHoldTime      = 4 * time.Minute
 	minHoldTime      = 20 * time.Second
 	kohPollTime      = 500 * time.Millisecond
 
-	sellSlippage       = 0.9
+	sellSlippageBps    = 1500
 	proxyRepeats       = 2
 	maxConcurrentHolds = 1
+
+	canaryInterval = 5 * time.Minute
 )
 
 type PumpSnipeBot struct {
@@ -32,31 +38,84 @@ type PumpSnipeBot struct {
 	blockchainClient *blockchain.BlockchainClient
 	coinInfoClient   *coinInfo.CoinInfoClient
 	pumpfunClient    *pumpfun.PumpFunClient
+	wallets          *walletPool.WalletPool
 
 	seenCoins   map[string]bool
 	seenCoinsMu sync.Mutex
 
 	coinsHeld   int
 	coinsHeldMu sync.Mutex
+
+	latency           *latency.Recorder
+	latencyExportPath string
+
+	sellAmount blockchain.SellAmount
+
+	canary          *blockchain.ProgramCanary
+	tradingDisabled atomic.Bool
 }
 
-func NewPumpSnipeBot(notifier notifications.Notifier, blockchainClient *blockchain.BlockchainClient, coinInfoClient *coinInfo.CoinInfoClient, pumpfunClient *pumpfun.PumpFunClient) *PumpSnipeBot {
+func NewPumpSnipeBot(notifier notifications.Notifier, blockchainClient *blockchain.BlockchainClient, coinInfoClient *coinInfo.CoinInfoClient, pumpfunClient *pumpfun.PumpFunClient, wallets *walletPool.WalletPool) *PumpSnipeBot {
 	return &PumpSnipeBot{
 		notifier:         notifier,
 		blockchainClient: blockchainClient,
 		coinInfoClient:   coinInfoClient,
 		pumpfunClient:    pumpfunClient,
+		wallets:          wallets,
 		seenCoins:        make(map[string]bool),
 		seenCoinsMu:      sync.Mutex{},
 		coinsHeld:        0,
 		coinsHeldMu:      sync.Mutex{},
+		latency:          latency.NewRecorder(),
+		sellAmount:       blockchain.SellAll(),
+	}
+}
+
+// SetSellAmount sets how much of each position is sold when its hold ends, e.g. SellForSol to take
+// the initial capital out and let the rest ride. Positions are sold in full by default.
+func (p *PumpSnipeBot) SetSellAmount(sellAmount blockchain.SellAmount) {
+	p.sellAmount = sellAmount
+}
+
+// SetLatencyExportPath has the pipeline latency histograms written to path as JSON after every buy
+func (p *PumpSnipeBot) SetLatencyExportPath(path string) {
+	p.latencyExportPath = path
+}
+
+// Latency holds a histogram per snipe pipeline stage, from the leader's notification to our confirmation
+func (p *PumpSnipeBot) Latency() *latency.Recorder {
+	return p.latency
+}
+
+// SetCanary has the bot check the pump program for drift every canaryInterval while it runs
+func (p *PumpSnipeBot) SetCanary(canary *blockchain.ProgramCanary) {
+	p.canary = canary
+}
+
+// DisableTrading stops the bot making new buys until it is restarted. Positions already held are
+// still sold. Only the first call sends an alert.
+func (p *PumpSnipeBot) DisableTrading(reason string) {
+	if !p.tradingDisabled.CompareAndSwap(false, true) {
+		return
 	}
+
+	slog.Error("Trading disabled", "reason", reason)
+	p.notifier.SendSMS(fmt.Sprintf("Trading disabled until restart: %.120s", reason), ethanPhoneNumber)
+}
+
+func (p *PumpSnipeBot) TradingDisabled() bool {
+	return p.tradingDisabled.Load()
 }
 
 func (p *PumpSnipeBot) Start(wallets []string) error {
 	slog.Info("Starting pump snipe bot for wallets", "wallets", wallets)
 
 	doneCh := make(chan interface{})
+	p.blockchainClient.StartBlockhashRefresher(doneCh)
+	if p.canary != nil {
+		go p.runCanary(doneCh)
+	}
+
 	wtsCh, wtsErrsCh, err := p.blockchainClient.SubscribeToWalletsTransactionSignatures(wallets, doneCh)
 	if err != nil {
 		return err
@@ -100,23 +159,51 @@ func (p *PumpSnipeBot) Start(wallets []string) error {
 
 }
 
+// runCanary checks the program straight away and then every canaryInterval, disabling trading
+// the first time it drifts. Checks that could not finish are only logged.
+func (p *PumpSnipeBot) runCanary(done <-chan interface{}) {
+	ticker := time.NewTicker(canaryInterval)
+	defer ticker.Stop()
+
+	for {
+		err := p.canary.Check()
+		switch {
+		case errors.Is(err, blockchain.ErrProgramDrift):
+			p.DisableTrading(err.Error())
+			return
+		case err != nil:
+			slog.Warn("Program canary check incomplete", "error", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
 func (p *PumpSnipeBot) handleTransaction(tx *blockchain.WalletTransactionSignature, errsCh chan<- *BotError) {
-	transaction, err := p.blockchainClient.GetTransactionDataWithRetries(tx.Signature, 3)
-	if err != nil {
-		errsCh <- &BotError{error: err, forceQuit: false}
+	if tx.Failed || p.TradingDisabled() {
 		return
 	}
 
-	if !isPumpfunBuy(transaction) {
-		return
+	receivedAt := tx.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
 	}
+	trace := p.latency.StartTrace(receivedAt)
 
-	mint, err := pumpfunMint(transaction)
+	mint, err := p.leaderBuyMint(tx, trace)
 	if err != nil {
 		errsCh <- &BotError{error: err, forceQuit: false}
 		return
 	}
 
+	if mint == "" {
+		return
+	}
+
 	if p.seenCoins[mint] {
 		return
 	}
@@ -126,11 +213,11 @@ func (p *PumpSnipeBot) handleTransaction(tx *blockchain.WalletTransactionSignatu
 	p.coinsHeldMu.Lock()
 	defer p.coinsHeldMu.Unlock()
 
-	go p.tryExecuteTrade(mint, errsCh)
+	go p.tryExecuteTrade(mint, tx, trace, errsCh)
 	return
 }
 
-func (p *PumpSnipeBot) tryExecuteTrade(mint string, errsCh chan<- *BotError) {
+func (p *PumpSnipeBot) tryExecuteTrade(mint string, leaderTx *blockchain.WalletTransactionSignature, trace *latency.Trace, errsCh chan<- *BotError) {
 	p.coinsHeldMu.Lock()
 	if p.coinsHeld >= maxConcurrentHolds {
 		slog.Info("Max concurrent holds reached", "max", maxConcurrentHolds, "current", p.coinsHeld)
@@ -141,32 +228,55 @@ func (p *PumpSnipeBot) tryExecuteTrade(mint string, errsCh chan<- *BotError) {
 	p.coinsHeld++
 	p.coinsHeldMu.Unlock()
 
-	p.handleBuyAndSell(mint, errsCh)
+	p.handleBuyAndSell(mint, leaderTx, trace, errsCh)
 
 	p.coinsHeldMu.Lock()
 	p.coinsHeld--
 	p.coinsHeldMu.Unlock()
 }
 
-func (p *PumpSnipeBot) handleBuyAndSell(mint string, errsCh chan<- *BotError) {
-	coinData, _, err := p.coinInfoClient.CoinDataFor(mint, false)
+func (p *PumpSnipeBot) handleBuyAndSell(mint string, leaderTx *blockchain.WalletTransactionSignature, trace *latency.Trace, errsCh chan<- *BotError) {
+	// the bonding curve accounts are derived locally, so metadata is only needed after the buy
+	coinDataTask := utils.DoAsync(func() (*pumpfun.CoinData, error) {
+		coinData, _, err := p.coinInfoClient.CoinDataFor(mint, false)
+		if err == nil {
+			trace.Since("coin data fetched")
+		}
+		return coinData, err
+	})
+
+	lease, err := p.wallets.Acquire(buyAmountSol)
 	if err != nil {
-		errsCh <- &BotError{error: err, forceQuit: false}
+		slog.Info("Skipping token", "mint", mint, "error", err)
 		return
 	}
 
-	slog.Info("Buying token", "mint", mint, "symbol", coinData.Symbol)
-	btr, err := p.blockchainClient.BuyTokenWithSol(mint, coinData.BondingCurve, coinData.AssociatedBondingCurve, buyAmountSol, buySlippage, os.Getenv("WALLET_PRIVATE_KEY"))
+	slog.Info("Buying token", "mint", mint, "wallet", lease.Signer().PublicKey())
+	btr, err := p.blockchainClient.BuyTokenWithSolForMint(mint, buyAmountSol, buySlippageBps, lease.Signer(), nil)
 	if err != nil {
+		p.wallets.Release(lease)
 		errsCh <- &BotError{error: err, forceQuit: false}
 		return
 	}
 
-	go p.handleNotifyBuy(mint, btr.TokenAmount, coinData.Symbol)
-	go p.handleHoldUntilSell(coinData, btr, errsCh)
+	p.recordBuyLatency(leaderTx, btr, trace)
+
+	coinData, err := utils.GetAsync(coinDataTask)
+	if err != nil {
+		// we hold the tokens regardless, so carry on with what the chain tells us
+		slog.Info("Failed to get coin data after buy", "mint", mint, "error", err)
+		coinData, err = coinDataFromChain(mint)
+		if err != nil {
+			errsCh <- &BotError{error: err, forceQuit: true}
+			return
+		}
+	}
+
+	go p.handleNotifyBuy(mint, btr, coinData.Symbol)
+	go p.handleHoldUntilSell(coinData, btr, lease, errsCh)
 }
 
-func (p *PumpSnipeBot) handleHoldUntilSell(coinData *pumpfun.CoinData, btr *blockchain.BuyTokenResult, errsCh chan<- *BotError) {
+func (p *PumpSnipeBot) handleHoldUntilSell(coinData *pumpfun.CoinData, btr *blockchain.BuyTokenResult, lease *walletPool.Lease, errsCh chan<- *BotError) {
 	time.Sleep(minHoldTime)
 	slog.Info("Min hold time reached", "mint", coinData.Mint, "symbol", coinData.Symbol)
 
@@ -204,27 +314,41 @@ func (p *PumpSnipeBot) handleHoldUntilSell(coinData *pumpfun.CoinData, btr *bloc
 
 	select {
 	case <-ticker.C:
-		go p.handleSell(coinData.Symbol, coinData, btr, errsCh, "max hold time reached")
+		go p.handleSell(coinData.Symbol, coinData, btr, lease, p.sellAmount, errsCh, "max hold time reached")
 	case <-kohCh:
-		go p.handleSell(coinData.Symbol, coinData, btr, errsCh, "koh reached")
+		go p.handleSell(coinData.Symbol, coinData, btr, lease, p.sellAmount, errsCh, "koh reached")
 	}
 
 }
 
-func (p *PumpSnipeBot) handleSell(symbol string, coinData *pumpfun.CoinData, btr *blockchain.BuyTokenResult, errsCh chan<- *BotError, reason string) {
-	slog.Info("Selling token", "mint", coinData.Mint, "symbol", symbol, "reason", reason)
-	txID, err := p.blockchainClient.SellToken(coinData.Mint, coinData.BondingCurve, coinData.AssociatedBondingCurve, btr.AssociatedTokenAccountAddress, sellSlippage, os.Getenv("WALLET_PRIVATE_KEY"))
+func (p *PumpSnipeBot) handleSell(symbol string, coinData *pumpfun.CoinData, btr *blockchain.BuyTokenResult, lease *walletPool.Lease, sellAmount blockchain.SellAmount, errsCh chan<- *BotError, reason string) {
+	slog.Info("Selling token", "mint", coinData.Mint, "symbol", symbol, "amount", sellAmount, "reason", reason)
+	str, err := p.sellToken(coinData, btr, lease, sellAmount, &blockchain.TradeOptions{CloseAccount: true})
 	if err != nil {
-		// retry once
-		slog.Info("Retrying sell", "mint", coinData.Mint, "symbol", symbol, "reason", reason)
-		txID, err = p.blockchainClient.SellToken(coinData.Mint, coinData.BondingCurve, coinData.AssociatedBondingCurve, btr.AssociatedTokenAccountAddress, sellSlippage, os.Getenv("WALLET_PRIVATE_KEY"))
+		// retry once at an escalated priority fee, a stuck sell is the most expensive failure
+		slog.Info("Retrying sell", "mint", coinData.Mint, "symbol", symbol, "reason", reason, "error", err)
+		str, err = p.sellToken(coinData, btr, lease, sellAmount, &blockchain.TradeOptions{Attempt: 1, CloseAccount: true})
 		if err != nil {
+			// free the wallet for new positions, the tokens are left for a manual sell
+			p.wallets.Strand(lease)
 			errsCh <- &BotError{error: err, forceQuit: true}
-			p.notifier.SendSMS(fmt.Sprintf("ERROR SELLING: %s failed: %v", pumpfunUrl(coinData.Mint), reason), ethanPhoneNumber)
+			p.notifier.SendSMS(fmt.Sprintf("ERROR SELLING: %s from %s failed: %v", pumpfunUrl(coinData.Mint), lease.Signer().PublicKey(), reason), ethanPhoneNumber)
 			return
 		}
 	}
 
-	slog.Info("Sold token", "mint", coinData.Mint, "txId", txID, "reason", reason)
-	p.handleNotifySell(coinData.Mint, symbol, reason)
+	slog.Info("Sold token", "mint", coinData.Mint, "txId", str.TxID, "tokenAmount", str.TokenAmount, "remaining", str.RemainingTokenAmount, "fill", str.Fill, "pool", str.Pool, "reason", reason)
+	// whatever a partial sell leaves is left to ride without tying up the wallet's budget
+	p.wallets.Release(lease)
+	p.handleNotifySell(coinData.Mint, symbol, btr, str, lease.Signer(), reason)
+}
+
+// sellToken sells through the pool straight away when the coin data already shows the coin
+// graduated. Otherwise SellTokenAmount sells on the curve, finding the pool itself if the coin
+// graduated while we held it.
+func (p *PumpSnipeBot) sellToken(coinData *pumpfun.CoinData, btr *blockchain.BuyTokenResult, lease *walletPool.Lease, sellAmount blockchain.SellAmount, opts *blockchain.TradeOptions) (*blockchain.SellTokenResult, error) {
+	if coinData.Complete && coinData.RaydiumPool != nil && *coinData.RaydiumPool != "" {
+		return p.blockchainClient.SellTokenOnPool(coinData.Mint, *coinData.RaydiumPool, btr.AssociatedTokenAccountAddress, sellAmount, sellSlippageBps, lease.Signer(), opts)
+	}
+	return p.blockchainClient.SellTokenAmount(coinData.Mint, coinData.BondingCurve, coinData.AssociatedBondingCurve, btr.AssociatedTokenAccountAddress, sellAmount, sellSlippageBps, lease.Signer(), opts)
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethanhosier/pumpfun-trade-bot/blockchain"
 	"github.com/ethanhosier/pumpfun-trade-bot/config"
 	"github.com/joho/godotenv"
 )
@@ -19,9 +20,10 @@ func TestPumpSnipeBot(t *testing.T) {
 	ticker := time.NewTicker(2 * time.Minute)
 
 	config := config.MustNewDefaultConfig()
-	bot := NewPumpSnipeBot(config.Notifier, config.BlockchainClient, config.CoinInfoClient, config.PumpFunClient)
+	bot := NewPumpSnipeBot(config.Notifier, config.BlockchainClient, config.CoinInfoClient, config.PumpFunClient, config.Wallets)
 
-	go bot.handleBuyAndSell("G791oHKLamcQmik9bxkW6M1XpFrJsavF1MfujjUdpump", nil)
+	leaderTx := &blockchain.WalletTransactionSignature{ReceivedAt: time.Now()}
+	go bot.handleBuyAndSell("G791oHKLamcQmik9bxkW6M1XpFrJsavF1MfujjUdpump", leaderTx, bot.latency.StartTrace(leaderTx.ReceivedAt), nil)
 
 	<-ticker.C
 }
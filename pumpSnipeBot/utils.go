@@ -3,60 +3,138 @@ package pumpSnipeBot
 import (
 	"fmt"
 	"log/slog"
-	"strings"
 
 	"github.com/ethanhosier/pumpfun-trade-bot/blockchain"
+	"github.com/ethanhosier/pumpfun-trade-bot/latency"
+	"github.com/ethanhosier/pumpfun-trade-bot/pumpfun"
+	"github.com/gagliardetto/solana-go"
 )
 
-func (p *PumpSnipeBot) handleNotifyBuy(mint string, tokenAmount float64, symbol string) {
+func (p *PumpSnipeBot) handleNotifyBuy(mint string, btr *blockchain.BuyTokenResult, symbol string) {
 	solPrice, err := p.coinInfoClient.SolPrice()
 	if err != nil {
 		slog.Error("Error getting SOL price", "error", err)
 		return
 	}
 
-	amountInPounds := solPrice * buyAmountSol
+	// the quoted spend until the fill has been read back
+	spentSol := buyAmountSol
+	if btr.Fill != nil {
+		spentSol = btr.Fill.Sol()
+	}
+	amountInPounds := solPrice * spentSol
 
-	err = p.notifier.SendSMS(fmt.Sprintf("BUY: %s £%v -> %v %s", pumpfunUrl(mint), amountInPounds, tokenAmount, symbol), ethanPhoneNumber)
+	err = p.notifier.SendSMS(fmt.Sprintf("BUY: %s £%v -> %v %s", pumpfunUrl(mint), amountInPounds, btr.TokenAmount, symbol), ethanPhoneNumber)
 	if err != nil {
 		slog.Error("Error sending SMS", "error", err)
 	}
 }
 
-func (p *PumpSnipeBot) handleNotifySell(mint string, symbol string, reason string) {
-	err := p.notifier.SendSMS(fmt.Sprintf("SELL: %s -> %s, %s", pumpfunUrl(mint), symbol, reason), ethanPhoneNumber)
+func (p *PumpSnipeBot) handleNotifySell(mint string, symbol string, btr *blockchain.BuyTokenResult, str *blockchain.SellTokenResult, wallet blockchain.Signer, reason string) {
+	message := fmt.Sprintf("SELL: %s -> %s, %s", pumpfunUrl(mint), symbol, reason)
+	if str.Pool != "" {
+		message += ", graduated so sold on Raydium"
+	}
+
+	if str.Fill != nil {
+		message += fmt.Sprintf(", received %.4f SOL", str.Fill.Sol())
+		// only a closed position has a realized PnL
+		if btr.Fill != nil && str.RemainingTokenAmount == 0 {
+			pnl := int64(str.Fill.SolAmount) - int64(btr.Fill.SolAmount) - int64(str.Fill.NetworkFee) - int64(btr.Fill.NetworkFee)
+			message += fmt.Sprintf(", PnL %+.4f SOL", float64(pnl)/float64(solana.LAMPORTS_PER_SOL))
+		}
+	}
+
+	if portfolio, err := p.blockchainClient.Portfolio(wallet.PublicKey().String()); err == nil {
+		message += fmt.Sprintf(", wallet %.4f SOL", portfolio.TotalValueInSol())
+	} else {
+		slog.Error("Error getting portfolio", "error", err)
+	}
+
+	err := p.notifier.SendSMS(message, ethanPhoneNumber)
 	if err != nil {
 		slog.Error("Error sending SMS", "error", err)
 	}
 }
 
-func isPumpfunBuy(tx *blockchain.Transaction) bool {
-	buy := false
-	pumpfun := false
+// leaderBuyMint returns the mint the leader bought, or "" if the transaction was not a successful
+// pump.fun buy. The trade event in the log notification is used when present to skip a
+// getTransaction round trip.
+func (p *PumpSnipeBot) leaderBuyMint(tx *blockchain.WalletTransactionSignature, trace *latency.Trace) (string, error) {
+	if tx.Failed {
+		return "", nil
+	}
 
-	for _, log := range tx.Meta.LogMessages {
-		if strings.Contains(log, "Instruction: Buy") {
-			buy = true
-		}
-		if strings.Contains(log, "Program 6EF8rrecthR5Dkzon8Nwu78hRvfCKubJ14M5uBEwF6P invoke") {
-			pumpfun = true
+	if events, err := blockchain.PumpEventsFromLogs(tx.Logs); err == nil {
+		if trade, ok := events.TradeBy(tx.Wallet); ok {
+			trace.Mark("leader logs decoded")
+			if !trade.IsBuy {
+				return "", nil
+			}
+			return trade.Mint.String(), nil
 		}
+	}
 
-		if buy && pumpfun {
-			return true
-		}
+	wallet, err := solana.PublicKeyFromBase58(tx.Wallet)
+	if err != nil {
+		return "", fmt.Errorf("invalid wallet address: %w", err)
 	}
 
-	return false
+	transaction, err := p.blockchainClient.GetTransactionDataWithRetries(tx.Signature, 3)
+	if err != nil {
+		return "", err
+	}
+	trace.Mark("leader transaction fetched")
+
+	classification := blockchain.ClassifyTransaction(transaction, wallet)
+	slog.Info("Classified leader transaction", "signature", tx.Signature, "kind", classification.Kind, "mint", classification.Mint, "succeeded", classification.Succeeded)
+
+	if classification.Kind != blockchain.PumpBuyTransaction || !classification.Succeeded {
+		return "", nil
+	}
+
+	return classification.Mint.String(), nil
 }
 
-func pumpfunMint(tx *blockchain.Transaction) (string, error) {
-	if len(tx.Meta.PostTokenBalances) == 0 {
-		return "", fmt.Errorf("no post token balances")
+// recordBuyLatency closes the trace at our confirmation, records how many slots we landed behind the
+// leader and exports the histograms
+func (p *PumpSnipeBot) recordBuyLatency(leaderTx *blockchain.WalletTransactionSignature, btr *blockchain.BuyTokenResult, trace *latency.Trace) {
+	trace.MarkAt("buy signed and sent", btr.SentAt)
+
+	if btr.Confirmation != nil {
+		confirmedAt := btr.SentAt.Add(btr.Confirmation.Elapsed)
+		trace.MarkAt("buy confirmed", confirmedAt)
+		trace.SinceAt("total", confirmedAt)
+
+		slot := btr.Confirmation.Slot
+		if btr.Fill != nil {
+			slot = btr.Fill.Slot
+		}
+		if leaderTx.Slot > 0 && slot > 0 {
+			p.latency.ObserveSlots("slot gap", int64(slot)-int64(leaderTx.Slot))
+		}
+	}
+
+	slog.Info("Snipe latency\n" + p.latency.String())
+
+	if p.latencyExportPath == "" {
+		return
+	}
+	if err := p.latency.WriteJSONFile(p.latencyExportPath); err != nil {
+		slog.Error("Error exporting latency", "error", err)
 	}
-	return tx.Meta.PostTokenBalances[0].Mint, nil
 }
 
 func pumpfunUrl(mint string) string {
 	return fmt.Sprintf("https://pump.fun/coin/%s", mint)
 }
+
+// coinDataFromChain fills in just what holding and selling need when the pump.fun API is unavailable
+func coinDataFromChain(mint string) (*pumpfun.CoinData, error) {
+	bondingCurve, associatedBondingCurve, err := blockchain.BondingCurveAddressesFor(mint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pumpfun.CoinData{Mint: mint, Symbol: mint, BondingCurve: bondingCurve.String(), AssociatedBondingCurve: associatedBondingCurve.String()}, nil
+}
@@ -0,0 +1,223 @@
+package walletPool
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/ethanhosier/pumpfun-trade-bot/blockchain"
+)
+
+const (
+	// left behind in each wallet on a sweep to pay for the transfer and keep the account rent exempt
+	sweepReserveSol = 0.001
+)
+
+var ErrNoWalletAvailable = errors.New("no wallet has budget or capacity for the position")
+
+type Selection int
+
+const (
+	RoundRobin Selection = iota
+	LeastLoaded
+)
+
+type WalletConfig struct {
+	Signer        blockchain.Signer
+	BudgetSol     float64 // most SOL the wallet may have in open positions at once
+	MaxConcurrent int     // most open positions at once
+}
+
+type wallet struct {
+	WalletConfig
+
+	committedSol float64
+	open         int
+	stranded     int // positions given up on with tokens still in the wallet
+}
+
+func (w *wallet) fits(amountSol float64) bool {
+	return w.open < w.MaxConcurrent && w.committedSol+amountSol <= w.BudgetSol
+}
+
+// Lease is a position's claim on a pool wallet's budget, held from buy until the position is closed
+type Lease struct {
+	wallet    *wallet
+	amountSol float64
+	released  bool
+}
+
+func (l *Lease) Signer() blockchain.Signer {
+	return l.wallet.Signer
+}
+
+// WalletPool spreads positions across several wallets so no single wallet can be fingerprinted
+// and copy traded
+type WalletPool struct {
+	blockchainClient *blockchain.BlockchainClient
+	selection        Selection
+
+	mu      sync.Mutex
+	wallets []*wallet
+	next    int // round robin cursor
+}
+
+func NewWalletPool(blockchainClient *blockchain.BlockchainClient, selection Selection, configs ...WalletConfig) (*WalletPool, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("wallet pool needs at least one wallet")
+	}
+
+	wallets := make([]*wallet, len(configs))
+	for i, config := range configs {
+		if config.Signer == nil || config.BudgetSol <= 0 || config.MaxConcurrent <= 0 {
+			return nil, fmt.Errorf("wallet %d needs a signer, a budget and a concurrency limit", i)
+		}
+		wallets[i] = &wallet{WalletConfig: config}
+	}
+
+	return &WalletPool{
+		blockchainClient: blockchainClient,
+		selection:        selection,
+		wallets:          wallets,
+	}, nil
+}
+
+// Acquire picks a wallet with room for a position of amountSol and commits that much of its budget
+func (p *WalletPool) Acquire(amountSol float64) (*Lease, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var chosen *wallet
+	switch p.selection {
+	case LeastLoaded:
+		for _, w := range p.wallets {
+			if w.fits(amountSol) && (chosen == nil || w.committedSol/w.BudgetSol < chosen.committedSol/chosen.BudgetSol) {
+				chosen = w
+			}
+		}
+	default:
+		for i := range p.wallets {
+			w := p.wallets[(p.next+i)%len(p.wallets)]
+			if w.fits(amountSol) {
+				chosen = w
+				p.next = (p.next + i + 1) % len(p.wallets)
+				break
+			}
+		}
+	}
+
+	if chosen == nil {
+		return nil, ErrNoWalletAvailable
+	}
+
+	chosen.committedSol += amountSol
+	chosen.open++
+
+	return &Lease{wallet: chosen, amountSol: amountSol}, nil
+}
+
+// Release returns the lease's budget to its wallet once the position is closed. Releasing twice is a no-op.
+func (p *WalletPool) Release(lease *Lease) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if lease.released {
+		return
+	}
+	lease.released = true
+
+	lease.wallet.committedSol -= lease.amountSol
+	lease.wallet.open--
+}
+
+// Strand releases a lease whose position could not be closed, so the wallet can keep trading, and
+// remembers that the wallet still holds its tokens so they can be sold or swept by hand later
+func (p *WalletPool) Strand(lease *Lease) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if lease.released {
+		return
+	}
+	lease.released = true
+
+	lease.wallet.committedSol -= lease.amountSol
+	lease.wallet.open--
+	lease.wallet.stranded++
+}
+
+// Stranded lists the wallets still holding tokens from positions that were given up on
+func (p *WalletPool) Stranded() []blockchain.Signer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	signers := []blockchain.Signer{}
+	for _, w := range p.wallets {
+		if w.stranded > 0 {
+			signers = append(signers, w.Signer)
+		}
+	}
+	return signers
+}
+
+// Signers lists every wallet in the pool
+func (p *WalletPool) Signers() []blockchain.Signer {
+	signers := make([]blockchain.Signer, len(p.wallets))
+	for i, w := range p.wallets {
+		signers[i] = w.Signer
+	}
+	return signers
+}
+
+// Fund tops up every pool wallet with amountSol from the funder, returning the transfer signatures
+func (p *WalletPool) Fund(funder blockchain.Signer, amountSol float64) ([]string, error) {
+	signatures := []string{}
+	for _, w := range p.wallets {
+		if w.Signer.PublicKey().Equals(funder.PublicKey()) {
+			continue
+		}
+
+		sig, err := p.blockchainClient.SendSolanaToWallet(amountSol, funder, w.Signer.PublicKey().String())
+		if err != nil {
+			return signatures, fmt.Errorf("failed to fund %s: %w", w.Signer.PublicKey(), err)
+		}
+		signatures = append(signatures, sig)
+	}
+
+	return signatures, nil
+}
+
+// Sweep moves the SOL from every pool wallet without open positions to the receiver, returning
+// the transfer signatures
+func (p *WalletPool) Sweep(receiverAddress string) ([]string, error) {
+	signatures := []string{}
+	for _, w := range p.wallets {
+		p.mu.Lock()
+		open := w.open
+		p.mu.Unlock()
+
+		if open > 0 || w.Signer.PublicKey().String() == receiverAddress {
+			continue
+		}
+
+		portfolio, err := p.blockchainClient.Portfolio(w.Signer.PublicKey().String())
+		if err != nil {
+			return signatures, fmt.Errorf("failed to get balance of %s: %w", w.Signer.PublicKey(), err)
+		}
+
+		amountSol := portfolio.SolBalance - sweepReserveSol
+		if amountSol <= 0 {
+			continue
+		}
+
+		sig, err := p.blockchainClient.SendSolanaToWallet(amountSol, w.Signer, receiverAddress)
+		if err != nil {
+			return signatures, fmt.Errorf("failed to sweep %s: %w", w.Signer.PublicKey(), err)
+		}
+		slog.Info("Swept wallet", "wallet", w.Signer.PublicKey(), "amountSol", amountSol, "txId", sig)
+		signatures = append(signatures, sig)
+	}
+
+	return signatures, nil
+}
@@ -0,0 +1,112 @@
+package walletPool
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+func testWallets(n int, budgetSol float64, maxConcurrent int) []WalletConfig {
+	configs := make([]WalletConfig, n)
+	for i := range configs {
+		configs[i] = WalletConfig{Signer: solana.NewWallet().PrivateKey, BudgetSol: budgetSol, MaxConcurrent: maxConcurrent}
+	}
+	return configs
+}
+
+func TestRoundRobin(t *testing.T) {
+	configs := testWallets(3, 1, 2)
+	pool, err := NewWalletPool(nil, RoundRobin, configs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		lease, err := pool.Acquire(0.1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := configs[i%3].Signer.PublicKey(); !lease.Signer().PublicKey().Equals(expected) {
+			t.Errorf("Expected lease %d from wallet %d, got %s", i, i%3, lease.Signer().PublicKey())
+		}
+	}
+
+	if _, err := pool.Acquire(0.1); !errors.Is(err, ErrNoWalletAvailable) {
+		t.Errorf("Expected every wallet at its concurrency limit, got %v", err)
+	}
+}
+
+func TestLeastLoaded(t *testing.T) {
+	configs := testWallets(2, 1, 10)
+	pool, _ := NewWalletPool(nil, LeastLoaded, configs...)
+
+	first, _ := pool.Acquire(0.5)
+	second, _ := pool.Acquire(0.2)
+	if first.Signer().PublicKey().Equals(second.Signer().PublicKey()) {
+		t.Errorf("Expected the second position in the empty wallet")
+	}
+
+	// wallet 0 has 0.5 committed and wallet 1 has 0.2
+	third, _ := pool.Acquire(0.2)
+	if !third.Signer().PublicKey().Equals(second.Signer().PublicKey()) {
+		t.Errorf("Expected the third position in the less loaded wallet")
+	}
+
+	pool.Release(first)
+	pool.Release(first)
+
+	fourth, _ := pool.Acquire(0.2)
+	if !fourth.Signer().PublicKey().Equals(first.Signer().PublicKey()) {
+		t.Errorf("Expected the released wallet to be least loaded")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	pool, _ := NewWalletPool(nil, RoundRobin, testWallets(1, 0.25, 10)...)
+
+	lease, err := pool.Acquire(0.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pool.Acquire(0.1); !errors.Is(err, ErrNoWalletAvailable) {
+		t.Errorf("Expected the position to exceed the wallet budget, got %v", err)
+	}
+
+	pool.Release(lease)
+	if _, err := pool.Acquire(0.1); err != nil {
+		t.Errorf("Expected budget back after release, got %v", err)
+	}
+
+	if _, err := NewWalletPool(nil, RoundRobin, WalletConfig{Signer: solana.NewWallet().PrivateKey}); err == nil {
+		t.Errorf("Expected a wallet without budget to be rejected")
+	}
+}
+
+func TestStrandAfterFailedSell(t *testing.T) {
+	configs := testWallets(2, 0.25, 1)
+	pool, _ := NewWalletPool(nil, RoundRobin, configs...)
+
+	lease, _ := pool.Acquire(0.2)
+	if _, err := pool.Acquire(0.2); err != nil {
+		t.Fatal(err)
+	}
+
+	// both sell attempts failed, so the position is given up on
+	pool.Strand(lease)
+	pool.Release(lease)
+
+	stranded := pool.Stranded()
+	if len(stranded) != 1 || !stranded[0].PublicKey().Equals(configs[0].Signer.PublicKey()) {
+		t.Errorf("Expected wallet 0 stranded, got %v", stranded)
+	}
+
+	next, err := pool.Acquire(0.2)
+	if err != nil {
+		t.Fatalf("Expected the stranded wallet's budget back, got %v", err)
+	}
+	if !next.Signer().PublicKey().Equals(configs[0].Signer.PublicKey()) {
+		t.Errorf("Expected the next position in the stranded wallet, got %s", next.Signer().PublicKey())
+	}
+}